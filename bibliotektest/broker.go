@@ -0,0 +1,372 @@
+// Package bibliotektest provides an in-memory MQTT broker and a harness for
+// testing services built on bibliotek without a running broker.
+package bibliotektest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"lib.hemtjan.st/internal/topic"
+	"lib.hemtjan.st/server"
+)
+
+// Broker is an in-memory MQTT broker. It keeps retained messages and records
+// every message published to it.
+type Broker struct {
+	mu       sync.Mutex
+	retained map[string]server.Message
+	log      []server.Message
+	changed  chan struct{}
+	conns    []*Conn
+}
+
+// NewBroker returns an empty broker.
+func NewBroker() *Broker {
+	return &Broker{
+		retained: map[string]server.Message{},
+		changed:  make(chan struct{}),
+	}
+}
+
+// Transport returns a new connection to the broker, to be passed to
+// [server.WithTransport].
+func (b *Broker) Transport() *Conn {
+	return &Conn{broker: b, up: make(chan struct{})}
+}
+
+// Publish publishes a message as if it was sent by another client.
+func (b *Broker) Publish(m server.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m.Payload = slices.Clone(m.Payload)
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+
+	b.log = append(b.log, m)
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	// Subscribers don't see the retain flag on messages published while
+	// they are subscribed.
+	m.Retain = false
+	for _, c := range b.conns {
+		if c.subscribed(m.Topic) {
+			c.deliver(m)
+		}
+	}
+}
+
+// Retained returns the message retained on the topic.
+func (b *Broker) Retained(topic string) (server.Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+// Messages returns every message published to the broker, oldest first.
+func (b *Broker) Messages() []server.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.log)
+}
+
+// Clear forgets the messages published so far. Retained messages are kept.
+func (b *Broker) Clear() {
+	b.mu.Lock()
+	b.log = nil
+	b.mu.Unlock()
+}
+
+// next returns the first message published on the topic after the first
+// skip messages on it, waiting until one is published or ctx is done.
+func (b *Broker) next(ctx context.Context, topic string, skip int) (server.Message, error) {
+	for {
+		b.mu.Lock()
+		n := 0
+		for _, m := range b.log {
+			if m.Topic != topic {
+				continue
+			}
+			if n == skip {
+				b.mu.Unlock()
+				return m, nil
+			}
+			n++
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return server.Message{}, ctx.Err()
+		}
+	}
+}
+
+var _ server.Transport = (*Conn)(nil)
+
+// Conn is a client connection to a [Broker]. It implements
+// [server.Transport].
+//
+// Messages it receives with QoS 1 or 2 are left to the server to
+// acknowledge, and are reported by [Conn.Unacked] until they are.
+type Conn struct {
+	broker *Broker
+
+	// filters and queue are guarded by broker.mu.
+	filters []string
+	queue   []server.Message
+	wake    chan struct{}
+	done    chan struct{}
+
+	mu        sync.Mutex
+	up        chan struct{}
+	will      server.Message
+	onUp      func()
+	onMessage server.MessageHandler
+	unacked   map[uint64]server.Message
+	lastID    uint64
+}
+
+func (c *Conn) OnConnectionUp(fn func()) {
+	c.mu.Lock()
+	c.onUp = fn
+	c.mu.Unlock()
+}
+
+func (c *Conn) OnMessage(fn server.MessageHandler) {
+	c.mu.Lock()
+	c.onMessage = fn
+	c.mu.Unlock()
+}
+
+// SetWill sets the message published if the connection is dropped with
+// [Conn.Drop].
+func (c *Conn) SetWill(m server.Message) {
+	c.mu.Lock()
+	c.will = m
+	c.mu.Unlock()
+}
+
+// Connect connects to the broker. It does nothing if the connection is
+// already up.
+func (c *Conn) Connect(ctx context.Context) error {
+	b := c.broker
+	b.mu.Lock()
+	if slices.Contains(b.conns, c) {
+		b.mu.Unlock()
+		return nil
+	}
+	c.filters = nil
+	c.queue = nil
+	c.wake = make(chan struct{}, 1)
+	c.done = make(chan struct{})
+	b.conns = append(b.conns, c)
+	b.mu.Unlock()
+
+	go c.run(c.wake, c.done)
+
+	c.mu.Lock()
+	close(c.up)
+	onUp := c.onUp
+	c.mu.Unlock()
+
+	if onUp != nil {
+		onUp()
+	}
+	return nil
+}
+
+func (c *Conn) AwaitConnection(ctx context.Context) error {
+	c.mu.Lock()
+	up := c.up
+	c.mu.Unlock()
+
+	select {
+	case <-up:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Conn) Disconnect(ctx context.Context) error {
+	c.drop()
+	return nil
+}
+
+// Drop drops the connection as if it was lost, and publishes the will
+// message. Call [Conn.Connect] to bring it back up.
+func (c *Conn) Drop() {
+	if !c.drop() {
+		return
+	}
+
+	c.mu.Lock()
+	will := c.will
+	c.mu.Unlock()
+	if will.Topic != "" {
+		c.broker.Publish(will)
+	}
+}
+
+// drop removes the connection from the broker, reporting whether it was
+// connected.
+func (c *Conn) drop() bool {
+	b := c.broker
+	b.mu.Lock()
+	connected := slices.Contains(b.conns, c)
+	b.conns = slices.DeleteFunc(b.conns, func(o *Conn) bool { return o == c })
+	b.mu.Unlock()
+
+	c.mu.Lock()
+	c.up = make(chan struct{})
+	c.mu.Unlock()
+
+	if connected {
+		close(c.done)
+	}
+	return connected
+}
+
+func (c *Conn) Publish(ctx context.Context, m *server.Message) error {
+	if !c.connected() {
+		return server.ErrConnectionDown
+	}
+	c.broker.Publish(*m)
+	return nil
+}
+
+func (c *Conn) Subscribe(ctx context.Context, subs ...server.Subscription) error {
+	if !c.connected() {
+		return server.ErrConnectionDown
+	}
+
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range subs {
+		if !slices.Contains(c.filters, s.Topic) {
+			c.filters = append(c.filters, s.Topic)
+		}
+		for t, m := range b.retained {
+			if topic.Match(s.Topic, t) {
+				c.deliver(m)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Conn) Unsubscribe(ctx context.Context, topics ...string) error {
+	if !c.connected() {
+		return server.ErrConnectionDown
+	}
+
+	b := c.broker
+	b.mu.Lock()
+	c.filters = slices.DeleteFunc(c.filters, func(f string) bool { return slices.Contains(topics, f) })
+	b.mu.Unlock()
+	return nil
+}
+
+func (c *Conn) connected() bool {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Contains(b.conns, c)
+}
+
+// subscribed reports whether the connection subscribed to a filter matching
+// the topic. It must be called with broker.mu held.
+func (c *Conn) subscribed(t string) bool {
+	return slices.ContainsFunc(c.filters, func(f string) bool { return topic.Match(f, t) })
+}
+
+// deliver queues a message for the connection. It must be called with
+// broker.mu held.
+func (c *Conn) deliver(m server.Message) {
+	c.queue = append(c.queue, m)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run passes queued messages to the message handler in order, outside of the
+// broker's lock so handlers can publish.
+func (c *Conn) run(wake, done chan struct{}) {
+	for {
+		select {
+		case <-wake:
+		case <-done:
+			return
+		}
+
+		for {
+			c.broker.mu.Lock()
+			if len(c.queue) == 0 {
+				c.broker.mu.Unlock()
+				break
+			}
+			m := c.queue[0]
+			c.queue = c.queue[1:]
+			c.broker.mu.Unlock()
+
+			if m.QoS > 0 {
+				m.Ack = c.track(m)
+			}
+
+			c.mu.Lock()
+			onMessage := c.onMessage
+			c.mu.Unlock()
+			if onMessage != nil {
+				onMessage(&m)
+			}
+		}
+	}
+}
+
+// track records a message as unacknowledged, and returns the function that
+// acknowledges it.
+func (c *Conn) track(m server.Message) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unacked == nil {
+		c.unacked = map[uint64]server.Message{}
+	}
+	c.lastID++
+	id := c.lastID
+	c.unacked[id] = m
+
+	return func() {
+		c.mu.Lock()
+		delete(c.unacked, id)
+		c.mu.Unlock()
+	}
+}
+
+// Unacked returns the messages received on the connection that haven't been
+// acknowledged, oldest first. Messages stay unacknowledged across reconnects,
+// but unlike with a real broker they aren't delivered again.
+func (c *Conn) Unacked() []server.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var msgs []server.Message
+	for _, id := range slices.Sorted(maps.Keys(c.unacked)) {
+		msgs = append(msgs, c.unacked[id])
+	}
+	return msgs
+}
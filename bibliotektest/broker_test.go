@@ -0,0 +1,123 @@
+package bibliotektest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lib.hemtjan.st/server"
+)
+
+// receive connects a new connection to the broker and returns the channel the
+// messages it receives are passed to.
+func receive(t *testing.T, b *Broker) (*Conn, <-chan *server.Message) {
+	t.Helper()
+
+	msgs := make(chan *server.Message, 10)
+	c := b.Transport()
+	c.OnMessage(func(m *server.Message) { msgs <- m })
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Disconnect(context.Background()) })
+	return c, msgs
+}
+
+func expect(t *testing.T, msgs <-chan *server.Message, topic, payload string) {
+	t.Helper()
+
+	select {
+	case m := <-msgs:
+		if m.Topic != topic || string(m.Payload) != payload {
+			t.Errorf("got %q on %s, want %q on %s", m.Payload, m.Topic, payload, topic)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no message on %s", topic)
+	}
+}
+
+func TestBrokerRetained(t *testing.T) {
+	b := NewBroker()
+	b.Publish(server.Message{Topic: "a/b", Payload: []byte("1"), Retain: true})
+
+	c, msgs := receive(t, b)
+	if err := c.Subscribe(context.Background(), server.Subscription{Topic: "a/+"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, msgs, "a/b", "1")
+
+	b.Publish(server.Message{Topic: "a/c", Payload: []byte("2")})
+	expect(t, msgs, "a/c", "2")
+
+	b.Publish(server.Message{Topic: "a/b", Retain: true})
+	expect(t, msgs, "a/b", "")
+	if _, ok := b.Retained("a/b"); ok {
+		t.Error("empty retained message didn't clear the topic")
+	}
+
+	if err := c.Unsubscribe(context.Background(), "a/+"); err != nil {
+		t.Fatal(err)
+	}
+	b.Publish(server.Message{Topic: "a/c", Payload: []byte("3")})
+	select {
+	case m := <-msgs:
+		t.Errorf("got %q on %s after unsubscribing", m.Payload, m.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConnReconnect(t *testing.T) {
+	b := NewBroker()
+	other, msgs := receive(t, b)
+	if err := other.Subscribe(context.Background(), server.Subscription{Topic: "will"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := b.Transport()
+	ups := 0
+	c.OnConnectionUp(func() { ups++ })
+	c.SetWill(server.Message{Topic: "will", Payload: []byte("offline")})
+
+	ctx := context.Background()
+	for range 2 {
+		if err := c.Connect(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ups != 1 {
+		t.Errorf("connection came up %d times, want once", ups)
+	}
+
+	c.Drop()
+	expect(t, msgs, "will", "offline")
+	if err := c.Publish(ctx, &server.Message{Topic: "x"}); !errors.Is(err, server.ErrConnectionDown) {
+		t.Errorf("publish on a dropped connection: got %v, want %v", err, server.ErrConnectionDown)
+	}
+
+	actx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := c.AwaitConnection(actx); err == nil {
+		t.Error("dropped connection reported as up")
+	}
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AwaitConnection(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ups != 2 {
+		t.Errorf("connection came up %d times, want twice", ups)
+	}
+
+	// A clean disconnect doesn't publish the will.
+	if err := c.Disconnect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-msgs:
+		t.Errorf("got %q on %s after a clean disconnect", m.Payload, m.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
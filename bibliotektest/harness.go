@@ -0,0 +1,261 @@
+package bibliotektest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"lib.hemtjan.st/device"
+	"lib.hemtjan.st/server"
+)
+
+// DefaultTimeout is how long a [Harness] waits for a message by default.
+const DefaultTimeout = time.Second
+
+// Harness runs a [server.Server] connected to an in-memory [Broker] for the
+// duration of a test.
+type Harness struct {
+	Broker *Broker
+	Server *server.Server
+	// Conn is the server's connection to the broker, which can be dropped
+	// to test how the server copes with a lost connection.
+	Conn *Conn
+
+	// Timeout is how long to wait for a message before failing the test.
+	Timeout time.Duration
+
+	t       testing.TB
+	mu      sync.Mutex
+	seen    map[string]int
+	stopped bool
+}
+
+// New starts a server with the options connected to a new broker. The server
+// is stopped when the test ends.
+func New(t testing.TB, opts ...server.Option) *Harness {
+	t.Helper()
+
+	b := NewBroker()
+	h := &Harness{
+		Broker:  b,
+		Conn:    b.Transport(),
+		Timeout: DefaultTimeout,
+		t:       t,
+		seen:    map[string]int{},
+	}
+
+	lw := &logWriter{t: t}
+	log := slog.New(slog.NewTextHandler(lw, nil))
+
+	opts = append(opts, server.WithTransport(h.Conn))
+	srv, err := server.New(context.Background(), log, "mqtt://bibliotektest", "bibliotektest", opts...)
+	if err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	h.Server = srv
+
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		h.mu.Lock()
+		stopped := h.stopped
+		h.mu.Unlock()
+		if !stopped {
+			h.Stop()
+		}
+		lw.close()
+	})
+
+	return h
+}
+
+// Stop stops the server, failing the test on error. The server is stopped
+// when the test ends if Stop isn't called.
+func (h *Harness) Stop() {
+	h.t.Helper()
+
+	h.mu.Lock()
+	h.stopped = true
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Server.Stop(ctx); err != nil {
+		h.t.Errorf("unable to stop server: %v", err)
+	}
+}
+
+// AddDevice adds the device to the server, failing the test on error.
+func (h *Harness) AddDevice(dev *device.Device) {
+	h.t.Helper()
+	if err := h.Server.AddDevice(context.Background(), dev); err != nil {
+		h.t.Fatalf("unable to add device: %v", err)
+	}
+}
+
+// DiscoveryTopic returns the topic the discovery config of the device is
+// published on.
+func (h *Harness) DiscoveryTopic(dev *device.Device) string {
+	return dev.DiscoveryTopicWithPrefix(h.Server.DiscoveryPrefix())
+}
+
+// Discovery returns the last discovery config published for the device,
+// decoded from JSON.
+func (h *Harness) Discovery(dev *device.Device) map[string]any {
+	h.t.Helper()
+
+	topic := h.DiscoveryTopic(dev)
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	if _, err := h.Broker.next(ctx, topic, 0); err != nil {
+		h.t.Fatalf("no discovery config published on %s", topic)
+	}
+
+	msgs := h.Broker.Messages()
+	var payload []byte
+	for _, m := range msgs {
+		if m.Topic == topic {
+			payload = m.Payload
+		}
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(payload, &got); err != nil {
+		h.t.Fatalf("invalid discovery config on %s: %v", topic, err)
+	}
+	return got
+}
+
+// AssertDiscovery checks that the last discovery config published for the
+// device matches the device as it is now.
+//
+// After a component has been removed the published config still holds a
+// placeholder for it, so use [Harness.AssertDiscoveryJSON] instead.
+func (h *Harness) AssertDiscovery(dev *device.Device) {
+	h.t.Helper()
+
+	buf, err := json.Marshal(dev)
+	if err != nil {
+		h.t.Fatalf("unable to marshal device: %v", err)
+	}
+	h.AssertDiscoveryJSON(dev, string(buf))
+}
+
+// AssertDiscoveryJSON checks that the last discovery config published for
+// the device is equal to the JSON document want, ignoring formatting and key
+// order.
+func (h *Harness) AssertDiscoveryJSON(dev *device.Device, want string) {
+	h.t.Helper()
+
+	var w map[string]any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		h.t.Fatalf("invalid JSON: %v", err)
+	}
+
+	got := h.Discovery(dev)
+	if !reflect.DeepEqual(got, w) {
+		gotBuf, _ := json.MarshalIndent(got, "", "  ")
+		wantBuf, _ := json.MarshalIndent(w, "", "  ")
+		h.t.Errorf("discovery config of %s:\ngot:\n%s\nwant:\n%s", h.DiscoveryTopic(dev), gotBuf, wantBuf)
+	}
+}
+
+// SendCommand publishes the payload on a command topic, as Home Assistant
+// does when the user interacts with an entity.
+func (h *Harness) SendCommand(topic, payload string) {
+	h.Broker.Publish(server.Message{Topic: topic, Payload: []byte(payload), QoS: 1})
+}
+
+// NextMessage returns the next message published on the topic. Successive
+// calls return successive messages, starting with the first one published
+// since the harness was created or [Harness.Clear] was called. It fails the
+// test if no message is published within the timeout.
+func (h *Harness) NextMessage(topic string) server.Message {
+	h.t.Helper()
+
+	h.mu.Lock()
+	skip := h.seen[topic]
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	m, err := h.Broker.next(ctx, topic, skip)
+	if err != nil {
+		h.t.Fatalf("no message published on %s within %s", topic, h.Timeout)
+	}
+
+	h.mu.Lock()
+	h.seen[topic] = skip + 1
+	h.mu.Unlock()
+
+	return m
+}
+
+// NextState returns the payload of the next message published on a state
+// topic, like [Harness.NextMessage].
+func (h *Harness) NextState(topic string) string {
+	h.t.Helper()
+	return string(h.NextMessage(topic).Payload)
+}
+
+// ExpectState checks that the next message published on a state topic has
+// the payload want.
+func (h *Harness) ExpectState(topic, want string) {
+	h.t.Helper()
+	if got := h.NextState(topic); got != want {
+		h.t.Errorf("state on %s: got %q, want %q", topic, got, want)
+	}
+}
+
+// Clear forgets the messages published so far, so that
+// [Harness.NextMessage] only returns messages published after it.
+func (h *Harness) Clear() {
+	h.Broker.Clear()
+	h.mu.Lock()
+	clear(h.seen)
+	h.mu.Unlock()
+}
+
+// RestartHomeAssistant simulates Home Assistant restarting by announcing it
+// as offline and then online on the status topic, which makes the server
+// publish its discovery configs and states again.
+//
+// Messages published before the restart are cleared first, so
+// [Harness.NextMessage] returns what is published in response to it.
+func (h *Harness) RestartHomeAssistant() {
+	h.Clear()
+
+	topic := path.Join(h.Server.DiscoveryPrefix(), "status")
+	h.Broker.Publish(server.Message{Topic: topic, Payload: []byte("offline"), QoS: 1})
+	h.Broker.Publish(server.Message{Topic: topic, Payload: []byte("online"), QoS: 1})
+}
+
+// logWriter writes the server's log to the test log, until the test ends.
+type logWriter struct {
+	t      testing.TB
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.t.Log(string(bytes.TrimRight(p, "\n")))
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+}
@@ -1,19 +1,188 @@
 package device
 
+import (
+	"slices"
+
+	"lib.hemtjan.st/platform"
+)
+
 type Class string
 
+// Sensor and number device classes.
 const (
-	Battery          Class = "battery"
-	Current          Class = "current"
-	Energy           Class = "energy"
-	Illuminance      Class = "illuminance"
-	Heat             Class = "heat"
-	RelativeHumidity Class = "humidity"
-	PM1              Class = "pm1"
-	PM25             Class = "pm25"
-	PM10             Class = "pm10"
-	Power            Class = "power"
-	Precipitation    Class = "precipitation"
-	Temperature      Class = "temperature"
-	Voltage          Class = "voltage"
+	AbsoluteHumidity              Class = "absolute_humidity"
+	ApparentPower                 Class = "apparent_power"
+	AQI                           Class = "aqi"
+	Area                          Class = "area"
+	AtmosphericPressure           Class = "atmospheric_pressure"
+	Battery                       Class = "battery"
+	BloodGlucoseConcentration     Class = "blood_glucose_concentration"
+	CarbonDioxide                 Class = "carbon_dioxide"
+	CarbonMonoxide                Class = "carbon_monoxide"
+	Conductivity                  Class = "conductivity"
+	Current                       Class = "current"
+	DataRate                      Class = "data_rate"
+	DataSize                      Class = "data_size"
+	Date                          Class = "date"
+	Distance                      Class = "distance"
+	Duration                      Class = "duration"
+	Energy                        Class = "energy"
+	EnergyDistance                Class = "energy_distance"
+	EnergyStorage                 Class = "energy_storage"
+	Enum                          Class = "enum"
+	Frequency                     Class = "frequency"
+	Gas                           Class = "gas"
+	Illuminance                   Class = "illuminance"
+	Irradiance                    Class = "irradiance"
+	Moisture                      Class = "moisture"
+	Monetary                      Class = "monetary"
+	NitrogenDioxide               Class = "nitrogen_dioxide"
+	NitrogenMonoxide              Class = "nitrogen_monoxide"
+	NitrousOxide                  Class = "nitrous_oxide"
+	Ozone                         Class = "ozone"
+	PH                            Class = "ph"
+	PM1                           Class = "pm1"
+	PM25                          Class = "pm25"
+	PM4                           Class = "pm4"
+	PM10                          Class = "pm10"
+	Power                         Class = "power"
+	PowerFactor                   Class = "power_factor"
+	Precipitation                 Class = "precipitation"
+	PrecipitationIntensity        Class = "precipitation_intensity"
+	Pressure                      Class = "pressure"
+	ReactiveEnergy                Class = "reactive_energy"
+	ReactivePower                 Class = "reactive_power"
+	RelativeHumidity              Class = "humidity"
+	SignalStrength                Class = "signal_strength"
+	SoundPressure                 Class = "sound_pressure"
+	Speed                         Class = "speed"
+	SulphurDioxide                Class = "sulphur_dioxide"
+	Temperature                   Class = "temperature"
+	Timestamp                     Class = "timestamp"
+	VolatileOrganicCompounds      Class = "volatile_organic_compounds"
+	VolatileOrganicCompoundsParts Class = "volatile_organic_compounds_parts"
+	Voltage                       Class = "voltage"
+	Volume                        Class = "volume"
+	VolumeFlowRate                Class = "volume_flow_rate"
+	VolumeStorage                 Class = "volume_storage"
+	Water                         Class = "water"
+	Weight                        Class = "weight"
+	WindDirection                 Class = "wind_direction"
+	WindSpeed                     Class = "wind_speed"
 )
+
+// Binary sensor device classes.
+const (
+	BatteryCharging Class = "battery_charging"
+	Cold            Class = "cold"
+	Connectivity    Class = "connectivity"
+	Door            Class = "door"
+	GarageDoor      Class = "garage_door"
+	Heat            Class = "heat"
+	Light           Class = "light"
+	Lock            Class = "lock"
+	Motion          Class = "motion"
+	Moving          Class = "moving"
+	Occupancy       Class = "occupancy"
+	Opening         Class = "opening"
+	Plug            Class = "plug"
+	Presence        Class = "presence"
+	Problem         Class = "problem"
+	Running         Class = "running"
+	Safety          Class = "safety"
+	Smoke           Class = "smoke"
+	Sound           Class = "sound"
+	Tamper          Class = "tamper"
+	Update          Class = "update"
+	Vibration       Class = "vibration"
+	Window          Class = "window"
+)
+
+// Cover device classes.
+const (
+	Awning  Class = "awning"
+	Blind   Class = "blind"
+	Curtain Class = "curtain"
+	Damper  Class = "damper"
+	Garage  Class = "garage"
+	Gate    Class = "gate"
+	Shade   Class = "shade"
+	Shutter Class = "shutter"
+)
+
+// Button device classes.
+const (
+	Identify Class = "identify"
+	Restart  Class = "restart"
+)
+
+// Switch device classes.
+const (
+	Outlet Class = "outlet"
+	Switch Class = "switch"
+)
+
+// Event device classes.
+const (
+	Button   Class = "button"
+	Doorbell Class = "doorbell"
+)
+
+// Update device classes.
+const (
+	Firmware Class = "firmware"
+)
+
+var number = []Class{
+	AbsoluteHumidity, ApparentPower, AQI, Area, AtmosphericPressure, Battery,
+	BloodGlucoseConcentration, CarbonDioxide, CarbonMonoxide, Conductivity,
+	Current, DataRate, DataSize, Distance, Duration, Energy, EnergyDistance,
+	EnergyStorage, Frequency, Gas, Illuminance, Irradiance, Moisture, Monetary,
+	NitrogenDioxide, NitrogenMonoxide, NitrousOxide, Ozone, PH, PM1, PM25, PM4,
+	PM10, Power, PowerFactor, Precipitation, PrecipitationIntensity, Pressure,
+	ReactiveEnergy, ReactivePower, RelativeHumidity, SignalStrength,
+	SoundPressure, Speed, SulphurDioxide, Temperature, VolatileOrganicCompounds,
+	VolatileOrganicCompoundsParts, Voltage, Volume, VolumeFlowRate,
+	VolumeStorage, Water, Weight, WindDirection, WindSpeed,
+}
+
+var classes = map[platform.Type][]Class{
+	platform.Sensor: append(slices.Clone(number), Date, Enum, Timestamp),
+	platform.Number: number,
+	platform.SensorBinary: {
+		Battery, BatteryCharging, CarbonMonoxide, Cold, Connectivity, Door,
+		GarageDoor, Gas, Heat, Light, Lock, Moisture, Motion, Moving, Occupancy,
+		Opening, Plug, Power, Presence, Problem, Running, Safety, Smoke, Sound,
+		Tamper, Update, Vibration, Window,
+	},
+	platform.Cover: {
+		Awning, Blind, Curtain, Damper, Door, Garage, Gate, Shade, Shutter,
+		Window,
+	},
+	platform.Button:  {Identify, Restart, Update},
+	platform.Switch:  {Outlet, Switch},
+	platform.Event:   {Button, Doorbell, Motion},
+	platform.Update:  {Firmware},
+	platform.Valve:   {Gas, Water},
+	platform.Climate: nil,
+}
+
+// For returns the device classes supported by the platform.
+//
+// The second return value is false if the platform is unknown, in which case
+// nothing can be said about which classes it supports.
+func For(p platform.Type) ([]Class, bool) {
+	c, ok := classes[p]
+	return slices.Clone(c), ok
+}
+
+// ValidFor reports whether the class can be used with the platform.
+//
+// Unknown platforms accept any class.
+func (c Class) ValidFor(p platform.Type) bool {
+	valid, ok := classes[p]
+	if !ok {
+		return true
+	}
+	return slices.Contains(valid, c)
+}
@@ -8,3 +8,12 @@
 	Total            Class = "total"
 	TotalIncreasing  Class = "total_increasing"
 )
+
+// Valid reports whether c is a state class Home Assistant knows.
+func (c Class) Valid() bool {
+	switch c {
+	case Measurement, MeasurementAngle, Total, TotalIncreasing:
+		return true
+	}
+	return false
+}
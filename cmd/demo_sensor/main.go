@@ -2,7 +2,6 @@
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"log/slog"
 	"math/rand"
@@ -48,7 +47,7 @@ func main() {
 				return
 			case <-time.After(3 * time.Second):
 				currentTemp := rand.Intn(10) + 15 // Random between 15-25
-				sensor.StateCh <- fmt.Sprintf("%d", currentTemp)
+				sensor.SetInt(int64(currentTemp))
 			}
 		}
 	}()
@@ -70,4 +69,8 @@ func main() {
 
 	<-ctx.Done()
 	stop()
+
+	sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	check(srv.Stop(sctx))
 }
@@ -1,15 +1,53 @@
 package component
 
+import "time"
+
 type UpdateChannel struct {
 	Topic   string
 	Channel <-chan string
+	// Retain publishes the updates as retained messages.
+	Retain bool
+	// Coalesce only publishes the newest update if updates arrive faster
+	// than they can be published.
+	Coalesce bool
 }
 
 type CommandChannel struct {
 	Topic   string
 	Channel chan<- string
+	// Queue configures how commands are queued while Channel isn't read.
+	Queue CommandQueue
+}
+
+// DefaultCommandQueueSize is the size of a command queue if none is set.
+const DefaultCommandQueueSize = 16
+
+// CommandQueue configures the queue commands wait in until the application
+// reads them.
+type CommandQueue struct {
+	// Size is the number of commands the queue holds, or
+	// [DefaultCommandQueueSize] if 0.
+	Size int
+	// Overflow decides what happens to a command when the queue is full.
+	Overflow OverflowPolicy
+	// Timeout is how long [Block] waits for room in the queue before
+	// dropping the command. If 0 it waits until the component is removed.
+	Timeout time.Duration
 }
 
+// OverflowPolicy decides what happens to a command arriving at a full
+// command queue.
+type OverflowPolicy int
+
+const (
+	// DropOldest drops the oldest queued command to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest drops the arriving command.
+	DropNewest
+	// Block waits for room in the queue, up to [CommandQueue.Timeout].
+	Block
+)
+
 type Updatable interface {
 	UpdateChannels() []UpdateChannel
 }
@@ -4,7 +4,6 @@
 	"path"
 
 	"lib.hemtjan.st/platform"
-	"lib.hemtjan.st/unit"
 )
 
 var _ Settable = (*Climate)(nil)
@@ -22,8 +21,6 @@ type Climate struct {
 	CurrentTemperatureTemplate string `json:"curr_temp_tpl,omitempty"`
 	CurrentTemperatureTopic    string `json:"curr_temp_t,omitempty"`
 
-	EnabledByDefault bool `json:"en"`
-
 	FanModeCommandTemplate string    `json:"fan_mode_cmd_tpl,omitempty"`
 	FanModeCommandTopic    string    `json:"fan_mode_cmd_t,omitempty"`
 	FanModeStateTemplate   string    `json:"fan_mode_stat_tpl,omitempty"`
@@ -49,8 +46,8 @@ type Climate struct {
 	Optimistic          bool   `json:"opt"`
 	PayloadAvailable    string `json:"pl_avail,omitempty"`
 	PayloadNotAvailable string `json:"pl_not_avail,omitempty"`
-	PayloadOff          string `json:"pl_on,omitempty"`
-	PayloadOn           string `json:"pl_off,omitempty"`
+	PayloadOff          string `json:"pl_off,omitempty"`
+	PayloadOn           string `json:"pl_on,omitempty"`
 
 	PowerCommandTemplate string `json:"power_command_template,omitempty"`
 	PowerCommandTopic    string `json:"power_command_topic,omitempty"`
@@ -63,44 +60,71 @@ type Climate struct {
 	PresetModeValueTemplate   string       `json:"pr_mode_val_tpl,omitempty"`
 	PresetModes               []PresetMode `json:"pr_modes,omitempty"`
 
-	SwingHorizontalModeCommandTemplateTemplate string                `json:",omitempty"`
-	SwingHorizontalModeCommandTopic            string                `json:",omitempty"`
-	SwingHorizontalModeStateTemplate           string                `json:",omitempty"`
-	SwingHorizontalModeStateTopic              string                `json:",omitempty"`
-	SwingHorizontalModes                       []SwingModeHorizontal `json:",omitempty"`
-	SwingModeCommandTemplate                   string                `json:",omitempty"`
-	SwingModeCommandTopic                      string                `json:",omitempty"`
-	SwingModeStateTemplate                     string                `json:",omitempty"`
-	SwingModeStateTopic                        string                `json:",omitempty"`
-	SwingModes                                 []SwingMode           `json:",omitempty"`
+	SwingHorizontalModeCommandTemplate string                `json:"swing_h_mode_cmd_tpl,omitempty"`
+	SwingHorizontalModeCommandTopic    string                `json:"swing_h_mode_cmd_t,omitempty"`
+	SwingHorizontalModeStateTemplate   string                `json:"swing_h_mode_stat_tpl,omitempty"`
+	SwingHorizontalModeStateTopic      string                `json:"swing_h_mode_stat_t,omitempty"`
+	SwingHorizontalModes               []SwingModeHorizontal `json:"swing_h_modes,omitempty"`
+	SwingModeCommandTemplate           string                `json:"swing_mode_cmd_tpl,omitempty"`
+	SwingModeCommandTopic              string                `json:"swing_mode_cmd_t,omitempty"`
+	SwingModeStateTemplate             string                `json:"swing_mode_stat_tpl,omitempty"`
+	SwingModeStateTopic                string                `json:"swing_mode_stat_t,omitempty"`
+	SwingModes                         []SwingMode           `json:"swing_modes,omitempty"`
 
 	TargetHumidityCommandTemplate string `json:"hum_cmd_tpl,omitempty"`
 	TargetHumidityCommandTopic    string `json:"hum_cmd_t,omitempty"`
 	TargetHumidityStateTemplate   string `json:"hum_state_tpl,omitempty"`
 	TargetHumidityStateTopic      string `json:"hum_stat_t,omitempty"`
 
-	TemperatureCommandTemplate     string           `json:"temp_cmd_tpl,omitempty"`
-	TemperatureCommandTopic        string           `json:"temp_cmd_t,omitempty"`
-	TemperatureHighCommandTemplate string           `json:"temp_hi_cmd_tpl,omitempty"`
-	TemperatureHighCommandTopic    string           `json:"temp_hi_cmd_t,omitempty"`
-	TemperatureHighStateTemplate   string           `json:"temp_hi_stat_tpl,omitempty"`
-	TemperatureHighStateTopic      string           `json:"temp_hi_stat_t,omitempty"`
-	TemperatureLowCommandTemplate  string           `json:"temp_lo_cmd_tpl,omitempty"`
-	TemperatureLowCommandTopic     string           `json:"temp_lo_cmd_t,omitempty"`
-	TemperatureLowStateTemplate    string           `json:"temp_lo_stat_tpl,omitempty"`
-	TemperatureLowStateTopic       string           `json:"temp_lo_stat_t,omitempty"`
-	TemperatureStateTemplate       string           `json:"temp_stat_tpl,omitempty"`
-	TemperatureStateTopic          string           `json:"temp_stat_t,omitempty"`
-	TemperatureUnit                unit.Measurement `json:"temp_unit,omitempty"`
-	TempStep                       float32          `json:"temp_step,omitzero"`
+	TemperatureCommandTemplate     string          `json:"temp_cmd_tpl,omitempty"`
+	TemperatureCommandTopic        string          `json:"temp_cmd_t,omitempty"`
+	TemperatureHighCommandTemplate string          `json:"temp_hi_cmd_tpl,omitempty"`
+	TemperatureHighCommandTopic    string          `json:"temp_hi_cmd_t,omitempty"`
+	TemperatureHighStateTemplate   string          `json:"temp_hi_stat_tpl,omitempty"`
+	TemperatureHighStateTopic      string          `json:"temp_hi_stat_t,omitempty"`
+	TemperatureLowCommandTemplate  string          `json:"temp_lo_cmd_tpl,omitempty"`
+	TemperatureLowCommandTopic     string          `json:"temp_lo_cmd_t,omitempty"`
+	TemperatureLowStateTemplate    string          `json:"temp_lo_stat_tpl,omitempty"`
+	TemperatureLowStateTopic       string          `json:"temp_lo_stat_t,omitempty"`
+	TemperatureStateTemplate       string          `json:"temp_stat_tpl,omitempty"`
+	TemperatureStateTopic          string          `json:"temp_stat_t,omitempty"`
+	TemperatureUnit                TemperatureUnit `json:"temp_unit,omitempty"`
+	TempStep                       float32         `json:"temp_step,omitzero"`
 
 	Template string `json:"val_tpl,omitempty"`
 
 	StateCh chan string `json:"-"`
 }
 
+func (c *Climate) SetTopicRoot(root string) {
+	c.reroot(root,
+		&c.CurrentHumidityTopic,
+		&c.CurrentTemperatureTopic,
+		&c.FanModeCommandTopic,
+		&c.FanModeStateTopic,
+		&c.JSONAttributesTopic,
+		&c.ModeCommandTopic,
+		&c.ModeStateTopic,
+		&c.PowerCommandTopic,
+		&c.PresetModeCommandTopic,
+		&c.PresetModeStateTopic,
+		&c.SwingHorizontalModeCommandTopic,
+		&c.SwingHorizontalModeStateTopic,
+		&c.SwingModeCommandTopic,
+		&c.SwingModeStateTopic,
+		&c.TargetHumidityCommandTopic,
+		&c.TargetHumidityStateTopic,
+		&c.TemperatureCommandTopic,
+		&c.TemperatureHighCommandTopic,
+		&c.TemperatureHighStateTopic,
+		&c.TemperatureLowCommandTopic,
+		&c.TemperatureLowStateTopic,
+		&c.TemperatureStateTopic,
+	)
+}
+
 func NewRadiator(id, name string) *Climate {
-	prefix := path.Join("homeassistant", "climate", id)
+	prefix := path.Join(DefaultTopicRoot, "climate", id)
 
 	return &Climate{
 		Base: Base{
@@ -109,10 +133,10 @@ func NewRadiator(id, name string) *Climate {
 			Platform:   platform.Climate,
 			BaseTopic:  prefix,
 			StateTopic: path.Join(prefix, "state"),
+			root:       DefaultTopicRoot,
 		},
 		StateCh: make(chan string),
 
-		EnabledByDefault:        true,
 		Modes:                   []Mode{ModeAuto, ModeOff, ModeHeat},
 		CurrentTemperatureTopic: path.Join(prefix, "current_temp"),
 		MinTemperature:          5.0,
@@ -121,11 +145,21 @@ func NewRadiator(id, name string) *Climate {
 		PresetModeStateTopic:    path.Join(prefix, "preset", "state"),
 		PresetModes:             []PresetMode{PresetEco, PresetAway, PresetBoost, PresetComfort},
 		TemperatureStateTopic:   path.Join(prefix, "temp", "state"),
-		TemperatureUnit:         unit.Celsius,
+		TemperatureUnit:         TemperatureUnitCelsius,
 		TempStep:                0.1,
 	}
 }
 
+// TemperatureUnit is the unit a climate device reports temperatures in.
+//
+// Unlike sensors, climate devices only accept C or F.
+type TemperatureUnit string
+
+const (
+	TemperatureUnitCelsius    TemperatureUnit = "C"
+	TemperatureUnitFahrenheit TemperatureUnit = "F"
+)
+
 type Mode string
 
 const (
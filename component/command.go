@@ -0,0 +1,49 @@
+package component
+
+import "context"
+
+// Command is a command received on a command topic.
+type Command struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+
+	// ResponseTopic and CorrelationData are set if the sender expects a
+	// reply, which can be sent with Reply.
+	ResponseTopic   string
+	CorrelationData []byte
+	UserProperties  []UserProperty
+
+	// Reply publishes a reply on the response topic, if there is one.
+	Reply func(ctx context.Context, payload []byte) error
+}
+
+// UserProperty is an MQTT v5 user property.
+type UserProperty struct {
+	Key, Value string
+}
+
+// CommandHandler handles a command. A returned error is logged and, if the
+// route has an error topic, published there.
+type CommandHandler func(ctx context.Context, cmd Command) error
+
+// CommandRoute routes the commands received on a topic to a handler.
+type CommandRoute struct {
+	Topic   string
+	Handler CommandHandler
+	// Queue configures how commands are queued while the handler is busy.
+	Queue CommandQueue
+	// ErrorTopic is where errors returned by the handler are published.
+	ErrorTopic string
+
+	// Echo publishes the payload of every command the handler accepts on
+	// StateTopic, keeping Home Assistant in sync without optimistic mode.
+	Echo       bool
+	StateTopic string
+	// Retain publishes echoed states as retained messages.
+	Retain bool
+}
+
+type Handleable interface {
+	CommandRoutes() []CommandRoute
+}
@@ -2,6 +2,8 @@
 
 import (
 	"encoding/json"
+	"path"
+	"strings"
 
 	"lib.hemtjan.st/class/device"
 	"lib.hemtjan.st/platform"
@@ -17,10 +19,22 @@ type BaseComponent interface {
 	GetBaseReference() *Base
 }
 
+// TopicRooter is implemented by components whose default topics can be moved
+// under another root.
+type TopicRooter interface {
+	SetTopicRoot(root string)
+}
+
+// DefaultTopicRoot is the root the constructors in this package build
+// default topics under.
+const DefaultTopicRoot = "homeassistant"
+
 var _ Settable = Base{}
 
 // Base are fields any component must have.
 type Base struct {
+	// Name is shown as is. MQTT discovery has no translation keys, so
+	// unlike the names of built-in integrations it isn't translated.
 	Name                 string         `json:"name"`
 	ID                   string         `json:"uniq_id"`
 	Platform             platform.Type  `json:"p"`
@@ -34,6 +48,43 @@ type Base struct {
 	AvailabilityMode     string         `json:"avty_mode,omitempty"`
 	AvailabilityTemplate string         `json:"avty_tpl,omitempty"`
 	AvailabilityTopic    string         `json:"avty_t,omitempty"`
+	EntityCategory       EntityCategory `json:"ent_cat,omitempty"`
+	EntityPicture        string         `json:"ent_pic,omitempty"`
+	EnabledByDefault     *bool          `json:"en,omitempty"`
+	Icon                 string         `json:"ic,omitempty"`
+	ObjectID             string         `json:"obj_id,omitempty"`
+	DefaultEntityID      string         `json:"def_ent_id,omitempty"`
+
+	// RetainState publishes the component's states as retained messages,
+	// so they survive a Home Assistant restart.
+	RetainState bool `json:"-"`
+
+	// CoalesceState makes state setters never block. A state that hasn't
+	// been published yet is replaced by a newer one, so only the newest
+	// state is published once the connection returns.
+	CoalesceState bool `json:"-"`
+
+	// CommandQueue configures how commands for the component are queued
+	// until the application reads them. It applies to the command channels
+	// and routes that don't set their own queue.
+	//
+	// CommandQueue, CommandErrorTopic and EchoCommands only apply to
+	// components that implement [Commandable] or [Handleable], such as an
+	// application's own component embedding Base. None of the components
+	// in this package do, so they are ignored on those.
+	CommandQueue CommandQueue `json:"-"`
+
+	// CommandErrorTopic is where errors returned by the component's
+	// command handlers are published, for routes without an error topic.
+	CommandErrorTopic string `json:"-"`
+
+	// EchoCommands publishes the commands the component's handlers accept
+	// as its state, on StateTopic for routes without a state topic.
+	EchoCommands bool `json:"-"`
+
+	// root is the topic root the default topics were built under, empty if
+	// the topics weren't built by a constructor.
+	root string
 }
 
 func (b Base) GetID() string {
@@ -52,6 +103,27 @@ func (b *Base) GetBaseReference() *Base {
 	return b
 }
 
+// SetTopicRoot moves the default topics of the component under root.
+//
+// Topics that were set explicitly are left untouched.
+func (b *Base) SetTopicRoot(root string) {
+	b.reroot(root)
+}
+
+func (b *Base) reroot(root string, topics ...*string) {
+	if b.root == "" || b.root == root {
+		return
+	}
+
+	topics = append(topics, &b.BaseTopic, &b.CommandTopic, &b.StateTopic, &b.AvailabilityTopic)
+	for _, t := range topics {
+		if rest, ok := strings.CutPrefix(*t, b.root+"/"); ok {
+			*t = path.Join(root, rest)
+		}
+	}
+	b.root = root
+}
+
 // Generic holds any component.
 //
 // Once you've determined platform and class, use [Generic.As] to get the
@@ -82,6 +154,16 @@ func (g *Generic) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// EntityCategory classifies a non-primary entity.
+//
+// Entities with a category are not shown on auto-generated dashboards.
+type EntityCategory string
+
+const (
+	EntityCategoryConfig     EntityCategory = "config"
+	EntityCategoryDiagnostic EntityCategory = "diagnostic"
+)
+
 // Availability represents the availability.
 type Availability struct {
 	PayloadAvailable    string `json:"pl_avail,omitempty"`
@@ -89,3 +171,23 @@ type Availability struct {
 	Topic               string `json:"t"`
 	Template            string `json:"val_tpl,omitempty"`
 }
+
+var _ Settable = Removed{}
+
+// Removed is published in place of a component in a device discovery config
+// to remove the component from the device.
+type Removed struct {
+	Platform platform.Type `json:"p"`
+}
+
+func (r Removed) GetID() string {
+	return ""
+}
+
+func (r Removed) GetPlatform() platform.Type {
+	return r.Platform
+}
+
+func (r Removed) GetDeviceClass() device.Class {
+	return ""
+}
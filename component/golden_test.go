@@ -0,0 +1,99 @@
+package component
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/class/state"
+	"lib.hemtjan.st/internal/golden"
+	"lib.hemtjan.st/unit"
+)
+
+var goldenComponents = []struct {
+	name string
+	new  func(t *testing.T) Settable
+}{
+	{"sensor_power", func(t *testing.T) Settable {
+		s, err := NewSensor("Power", "power", device.Power, state.Measurement, unit.Watt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}},
+	{"sensor_enum", func(t *testing.T) Settable {
+		s, err := NewSensor("Mode", "mode", device.Enum, "", unit.None)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Options = []string{"eco", "comfort"}
+		return s
+	}},
+	{"temp_sensor", func(t *testing.T) Settable {
+		return NewTempSensor("Temperature", "temp")
+	}},
+	{"battery_sensor", func(t *testing.T) Settable {
+		return NewBatterySensor("Battery", "battery")
+	}},
+	{"binary_sensor_door", func(t *testing.T) Settable {
+		return NewBinarySensor("Door", "door", device.Door)
+	}},
+	{"radiator", func(t *testing.T) Settable {
+		return NewRadiator("radiator", "Radiator")
+	}},
+	{"radiator_payloads", func(t *testing.T) Settable {
+		c := NewRadiator("radiator", "Radiator")
+		c.PayloadOn = "ON"
+		c.PayloadOff = "OFF"
+		c.SwingModes = []SwingMode{SwingModeOn, SwingModeOff}
+		c.SwingModeCommandTopic = "homeassistant/climate/radiator/swing/set"
+		return c
+	}},
+}
+
+func TestGoldenComponents(t *testing.T) {
+	for _, tc := range goldenComponents {
+		t.Run(tc.name, func(t *testing.T) {
+			cmp := tc.new(t)
+			if err := Validate(cmp); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+
+			got, err := json.MarshalIndent(cmp, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.Assert(t, filepath.Join("testdata", tc.name+".json"), got)
+		})
+	}
+}
+
+func TestGenericRoundTrip(t *testing.T) {
+	for _, tc := range goldenComponents {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var g Generic
+			if err := json.Unmarshal(want, &g); err != nil {
+				t.Fatal(err)
+			}
+			cmp := tc.new(t)
+			if g.GetID() != cmp.GetID() || g.GetPlatform() != cmp.GetPlatform() || g.GetDeviceClass() != cmp.GetDeviceClass() {
+				t.Errorf("Generic decoded as %q/%q/%q, want %q/%q/%q",
+					g.GetID(), g.GetPlatform(), g.GetDeviceClass(),
+					cmp.GetID(), cmp.GetPlatform(), cmp.GetDeviceClass())
+			}
+
+			got, err := json.Marshal(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.AssertJSONEqual(t, got, want)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package component
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"lib.hemtjan.st/platform"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[platform.Type]func() Settable{
+		platform.Climate:      func() Settable { return &Climate{StateCh: make(chan string)} },
+		platform.Sensor:       func() Settable { return &Sensor{StateCh: make(chan string, 1)} },
+		platform.SensorBinary: func() Settable { return &Sensor{StateCh: make(chan string, 1)} },
+	}
+)
+
+// Register sets the constructor of the components of a platform, used by
+// [Decode] to decode their discovery configs. The constructor must return a
+// pointer to an empty component.
+func Register(p platform.Type, fn func() Settable) {
+	registryMu.Lock()
+	registry[p] = fn
+	registryMu.Unlock()
+}
+
+// New returns an empty component of the platform, or a [*Generic] if no
+// constructor is registered for it.
+func New(p platform.Type) Settable {
+	registryMu.RLock()
+	fn, ok := registry[p]
+	registryMu.RUnlock()
+
+	if !ok {
+		return &Generic{}
+	}
+	return fn()
+}
+
+// Decode decodes the discovery config of a component into the type
+// registered for its platform, or a [*Generic] if there is none. A config
+// that only holds the platform decodes into [Removed].
+//
+// Keys may be abbreviated or not. They are renamed to the spelling the
+// component's fields use, except in the raw config a [*Generic] keeps.
+func Decode(data []byte) (Settable, error) {
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	var p platform.Type
+	for _, k := range []string{"p", "platform"} {
+		if v, ok := cfg[k]; ok {
+			if err := json.Unmarshal(v, &p); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	if len(cfg) == 1 && p != "" {
+		return Removed{Platform: p}, nil
+	}
+
+	cmp := New(p)
+	normalizeKeys(cfg, jsonKeys(reflect.TypeOf(cmp)))
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, cmp); err != nil {
+		return nil, err
+	}
+	if g, ok := cmp.(*Generic); ok {
+		g.Raw = data
+	}
+	return cmp, nil
+}
+
+// normalizeKeys renames the keys of a config that aren't in keys to their
+// abbreviated or full spelling, if that is in keys and not already set.
+func normalizeKeys(cfg map[string]json.RawMessage, keys map[string]bool) {
+	for _, k := range slices.Sorted(maps.Keys(cfg)) {
+		if keys[k] {
+			continue
+		}
+		for _, alt := range []string{abbreviated[k], fullKey(k)} {
+			if _, set := cfg[alt]; keys[alt] && !set {
+				cfg[alt] = cfg[k]
+				delete(cfg, k)
+				break
+			}
+		}
+	}
+}
+
+// jsonKeys returns the JSON keys of the fields of a struct, including those
+// of embedded structs.
+func jsonKeys(t reflect.Type) map[string]bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	keys := map[string]bool{}
+	if t.Kind() != reflect.Struct {
+		return keys
+	}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			maps.Copy(keys, jsonKeys(f.Type))
+			continue
+		}
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" && f.IsExported() {
+			keys[name] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,131 @@
+package component
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/internal/golden"
+	"lib.hemtjan.st/platform"
+	"lib.hemtjan.st/unit"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tc := range goldenComponents {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Decode(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gt, wt := reflect.TypeOf(got), reflect.TypeOf(tc.new(t)); gt != wt {
+				t.Fatalf("decoded into %v, want %v", gt, wt)
+			}
+
+			buf, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.AssertJSONEqual(t, buf, want)
+		})
+	}
+}
+
+func TestDecodeFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Settable
+	}{
+		{
+			name: "unknown platform",
+			data: `{"p":"light","uniq_id":"lamp","cmd_t":"lamp/set"}`,
+			want: &Generic{
+				Base: Base{ID: "lamp", Platform: "light", CommandTopic: "lamp/set"},
+				Raw:  json.RawMessage(`{"p":"light","uniq_id":"lamp","cmd_t":"lamp/set"}`),
+			},
+		},
+		{
+			name: "removed",
+			data: `{"p":"sensor"}`,
+			want: Removed{Platform: platform.Sensor},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	const p platform.Type = "bibliotek_test"
+	Register(p, func() Settable { return &Sensor{} })
+
+	got, err := Decode([]byte(`{"p":"bibliotek_test","uniq_id":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*Sensor); !ok {
+		t.Errorf("decoded into %T, want *Sensor", got)
+	}
+}
+
+func TestDecodeKeySpellings(t *testing.T) {
+	got, err := Decode([]byte(`{"platform":"sensor","unique_id":"temp","state_topic":"node/temp",` +
+		`"device_class":"temperature","unit_of_measurement":"°C","val_tpl":"{{ value }}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := got.(*Sensor)
+	if !ok {
+		t.Fatalf("decoded into %T, want *Sensor", got)
+	}
+	if s.ID != "temp" || s.Platform != platform.Sensor || s.StateTopic != "node/temp" ||
+		s.DeviceClass != device.Temperature || s.Unit != unit.Celsius || s.Template != "{{ value }}" {
+		t.Errorf("full keys not decoded: %+v", s)
+	}
+
+	// Climate spells some keys out in full, so abbreviated ones are
+	// expanded.
+	got, err = Decode([]byte(`{"p":"climate","uniq_id":"radiator","curr_hum_t":"node/humidity",` +
+		`"power_command_topic":"node/power","mode_command_topic":"node/mode"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := got.(*Climate)
+	if !ok {
+		t.Fatalf("decoded into %T, want *Climate", got)
+	}
+	if c.CurrentHumidityTopic != "node/humidity" || c.PowerCommandTopic != "node/power" || c.ModeCommandTopic != "node/mode" {
+		t.Errorf("keys not decoded: %+v", c)
+	}
+
+	// Generic keeps the config as it was received.
+	const light = `{"platform":"light","unique_id":"lamp","command_topic":"lamp/set"}`
+	got, err = Decode([]byte(light))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, ok := got.(*Generic)
+	if !ok {
+		t.Fatalf("decoded into %T, want *Generic", got)
+	}
+	if g.ID != "lamp" || g.Platform != "light" || g.CommandTopic != "lamp/set" || string(g.Raw) != light {
+		t.Errorf("got %+v with raw config %s", g.Base, g.Raw)
+	}
+}
@@ -1,7 +1,10 @@
 package component
 
 import (
+	"math"
 	"path"
+	"strconv"
+	"time"
 
 	"lib.hemtjan.st/class/device"
 	"lib.hemtjan.st/class/state"
@@ -11,42 +14,195 @@
 
 var _ Settable = (*Sensor)(nil)
 
+// PayloadNone is the state payload Home Assistant interprets as an unknown
+// sensor value.
+const PayloadNone = "None"
+
+// Sensor is an MQTT sensor or binary sensor integration
+//
+// See: https://www.home-assistant.io/integrations/sensor.mqtt/
 type Sensor struct {
 	Base
-	Template string           `json:"val_tpl,omitempty"`
-	Unit     unit.Measurement `json:"unit_of_meas,omitempty"`
-	State    state.Class      `json:"stat_cla,omitempty"`
-	StateCh  chan string      `json:"-"`
+	Template                  string           `json:"val_tpl,omitempty"`
+	Unit                      unit.Measurement `json:"unit_of_meas,omitempty"`
+	State                     state.Class      `json:"stat_cla,omitempty"`
+	SuggestedDisplayPrecision *uint            `json:"sug_dsp_prc,omitempty"`
+
+	// ExpireAfter is the number of seconds after which the state expires
+	// if it hasn't been updated.
+	ExpireAfter            uint   `json:"exp_aft,omitzero"`
+	ForceUpdate            bool   `json:"frc_upd,omitempty"`
+	LastResetValueTemplate string `json:"lrst_val_tpl,omitempty"`
+
+	// Options are the possible states of a sensor with the
+	// [device.Enum] class.
+	Options []string `json:"ops,omitempty"`
+
+	JSONAttributesTemplate string `json:"json_attr_tpl,omitempty"`
+	JSONAttributesTopic    string `json:"json_attr_t,omitempty"`
+
+	// StateCh carries the states set on the sensor. It must be buffered
+	// if [Base.CoalesceState] is set, as the constructors make it.
+	StateCh chan string `json:"-"`
+}
+
+func (s *Sensor) SetTopicRoot(root string) {
+	s.reroot(root, &s.JSONAttributesTopic)
 }
 
 func (s *Sensor) UpdateChannels() []UpdateChannel {
 	if s.StateTopic == "" {
 		return nil
 	}
-	return []UpdateChannel{{Topic: s.StateTopic, Channel: s.StateCh}}
+	return []UpdateChannel{{
+		Topic:    s.StateTopic,
+		Channel:  s.StateCh,
+		Retain:   s.RetainState,
+		Coalesce: s.CoalesceState,
+	}}
+}
+
+// send publishes a state. With [Base.CoalesceState] set it never blocks,
+// replacing the previous state if it hasn't been picked up yet. That needs a
+// buffered StateCh, on an unbuffered one the state is dropped if it can't be
+// handed over straight away.
+func (s *Sensor) send(payload string) {
+	if !s.CoalesceState {
+		s.StateCh <- payload
+		return
+	}
+
+	if cap(s.StateCh) == 0 {
+		select {
+		case s.StateCh <- payload:
+		default:
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.StateCh <- payload:
+			return
+		default:
+		}
+		select {
+		case <-s.StateCh:
+		default:
+		}
+	}
+}
+
+// SetFloat publishes v as the state of the sensor.
+//
+// The value is formatted with [Sensor.SuggestedDisplayPrecision] decimals if set, or with the
+// fewest digits that represent it otherwise. NaN and infinities are published
+// as [PayloadNone].
+func (s *Sensor) SetFloat(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		s.SetNone()
+		return
+	}
+
+	prec := -1
+	if s.SuggestedDisplayPrecision != nil {
+		prec = int(*s.SuggestedDisplayPrecision)
+	}
+	s.send(strconv.FormatFloat(v, 'f', prec, 64))
+}
+
+// SetFloatFrom converts v from the given unit to the sensor's unit and
+// publishes it as the state of the sensor.
+//
+// See [unit.Convert] for the supported conversions.
+func (s *Sensor) SetFloatFrom(v float64, from unit.Measurement) error {
+	v, err := unit.Convert(v, from, s.Unit)
+	if err != nil {
+		return err
+	}
+	s.SetFloat(v)
+	return nil
+}
+
+// SetInt publishes v as the state of the sensor.
+func (s *Sensor) SetInt(v int64) {
+	if s.SuggestedDisplayPrecision != nil && *s.SuggestedDisplayPrecision > 0 {
+		s.SetFloat(float64(v))
+		return
+	}
+	s.send(strconv.FormatInt(v, 10))
+}
+
+// SetTime publishes t as the state of the sensor.
+//
+// Sensors with the [device.Date] class publish the date only, all others
+// publish t in RFC 3339 format as expected by the [device.Timestamp] class.
+func (s *Sensor) SetTime(t time.Time) {
+	if s.DeviceClass == device.Date {
+		s.send(t.Format(time.DateOnly))
+		return
+	}
+	s.send(t.Format(time.RFC3339))
+}
+
+// SetDuration publishes d as the state of the sensor, expressed in the
+// sensor's unit. Sensors without a time unit publish d in seconds.
+func (s *Sensor) SetDuration(d time.Duration) {
+	switch s.Unit {
+	case unit.MicroSecond:
+		s.SetFloat(float64(d) / float64(time.Microsecond))
+	case unit.MilliSecond:
+		s.SetFloat(float64(d) / float64(time.Millisecond))
+	case unit.Minute:
+		s.SetFloat(d.Minutes())
+	case unit.Hour:
+		s.SetFloat(d.Hours())
+	case unit.Day:
+		s.SetFloat(d.Hours() / 24)
+	default:
+		s.SetFloat(d.Seconds())
+	}
+}
+
+// SetNone publishes [PayloadNone], resetting the sensor to unknown.
+func (s *Sensor) SetNone() {
+	s.send(PayloadNone)
+}
+
+// NewSensor creates a sensor publishing its state on a default topic.
+//
+// An error is returned if the sensor fails [Validate], such as when the unit
+// isn't accepted for the device class.
+func NewSensor(name, id string, class device.Class, state state.Class, unit unit.Measurement) (*Sensor, error) {
+	s := newSensor(name, id, class, state, unit)
+	if err := Validate(s); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func NewSensor(name, id string, class device.Class, state state.Class, unit unit.Measurement) *Sensor {
+func newSensor(name, id string, class device.Class, state state.Class, unit unit.Measurement) *Sensor {
 	return &Sensor{
 		Base: Base{
 			ID:          id,
 			Name:        name,
 			Platform:    platform.Sensor,
 			DeviceClass: class,
-			StateTopic:  path.Join("homeassistant", "sensor", id, "state"),
+			StateTopic:  path.Join(DefaultTopicRoot, "sensor", id, "state"),
+			root:        DefaultTopicRoot,
 		},
-		StateCh: make(chan string),
+		StateCh: make(chan string, 1),
 		Unit:    unit,
 		State:   state,
 	}
 }
 
 func NewTempSensor(name, id string) *Sensor {
-	return NewSensor(name, id, device.Temperature, state.Measurement, unit.Celsius)
+	return newSensor(name, id, device.Temperature, state.Measurement, unit.Celsius)
 }
 
 func NewBatterySensor(name, id string) *Sensor {
-	return NewSensor(name, id, device.Battery, state.Measurement, unit.Percent)
+	return newSensor(name, id, device.Battery, state.Measurement, unit.Percent)
 }
 
 func NewBinarySensor(name, id string, class device.Class) *Sensor {
@@ -56,8 +212,9 @@ func NewBinarySensor(name, id string, class device.Class) *Sensor {
 			Name:        name,
 			Platform:    platform.SensorBinary,
 			DeviceClass: class,
-			StateTopic:  path.Join("homeassistant", "binary_sensor", id, "state"),
+			StateTopic:  path.Join(DefaultTopicRoot, "binary_sensor", id, "state"),
+			root:        DefaultTopicRoot,
 		},
-		StateCh: make(chan string),
+		StateCh: make(chan string, 1),
 	}
 }
@@ -0,0 +1,30 @@
+package component
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSensorCoalesce(t *testing.T) {
+	s := NewTempSensor("Temperature", "temp")
+	s.CoalesceState = true
+
+	s.SetInt(1)
+	s.SetInt(2)
+	if got := <-s.StateCh; got != "2" {
+		t.Errorf("got %q, want the newest state", got)
+	}
+}
+
+func TestSensorCoalesceUnbuffered(t *testing.T) {
+	s := NewTempSensor("Temperature", "temp")
+	s.CoalesceState = true
+	s.StateCh = make(chan string)
+
+	// Must not block or spin with nobody receiving.
+	s.SetInt(1)
+
+	if err := Validate(s); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Validate = %v, want %v", err, ErrInvalidValue)
+	}
+}
@@ -0,0 +1,229 @@
+package component
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/platform"
+)
+
+// ErrInvalidDeviceClass is returned when a component uses a device class its
+// platform doesn't support.
+var ErrInvalidDeviceClass = errors.New("invalid device class for platform")
+
+// ErrInvalidUnit is returned when a sensor uses a unit its device class
+// doesn't accept.
+var ErrInvalidUnit = errors.New("invalid unit for device class")
+
+// ErrMissingKey is returned when a config lacks a key Home Assistant
+// requires.
+var ErrMissingKey = errors.New("missing required key")
+
+// ErrUnknownKey is returned when a config has a key Home Assistant doesn't
+// know, abbreviated or not.
+var ErrUnknownKey = errors.New("unknown key")
+
+// ErrInvalidValue is returned when a field holds a value Home Assistant
+// doesn't accept.
+var ErrInvalidValue = errors.New("invalid value")
+
+// ErrMissingTopic is returned when a component handles updates or commands on
+// a topic its config doesn't tell Home Assistant about.
+var ErrMissingTopic = errors.New("topic not in config")
+
+// requiredKeys are the keys each platform requires besides the platform and
+// unique ID.
+var requiredKeys = map[platform.Type][]string{
+	platform.Button:       {"cmd_t"},
+	platform.Event:        {"stat_t", "evt_typ"},
+	platform.Number:       {"cmd_t"},
+	platform.Sensor:       {"stat_t"},
+	platform.SensorBinary: {"stat_t"},
+	platform.Switch:       {"cmd_t"},
+}
+
+// Validate checks the component for configuration Home Assistant would
+// reject.
+//
+// It checks that the required keys are set, that every key is one Home
+// Assistant knows, that the topics the component publishes updates on and
+// receives commands on are in its config, and that enumerated fields hold
+// allowed values. All problems found are returned joined together.
+func Validate(s Settable) error {
+	if _, ok := s.(Removed); ok {
+		return nil
+	}
+
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.GetID(), err)
+	}
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return fmt.Errorf("%s: %w", s.GetID(), err)
+	}
+
+	p := &problems{id: s.GetID()}
+	if p.id == "" {
+		p.id = string(s.GetPlatform())
+	}
+
+	// Keys
+	for _, k := range slices.Sorted(maps.Keys(cfg)) {
+		if !knownKeys[k] {
+			p.add(ErrUnknownKey, "%q", k)
+		}
+	}
+	for _, k := range append([]string{"p", "uniq_id"}, requiredKeys[s.GetPlatform()]...) {
+		if !hasKey(cfg, k) {
+			p.add(ErrMissingKey, "%q", k)
+		}
+	}
+
+	// Topics
+	topics := configTopics(cfg)
+	if u, ok := s.(Updatable); ok {
+		for _, c := range u.UpdateChannels() {
+			if !slices.Contains(topics, c.Topic) {
+				p.add(ErrMissingTopic, "state topic %q", c.Topic)
+			}
+		}
+	}
+	if c, ok := s.(Commandable); ok {
+		for _, c := range c.CommandChannels() {
+			if c.Topic == "" || !slices.Contains(topics, c.Topic) {
+				p.add(ErrMissingTopic, "command topic %q", c.Topic)
+			}
+		}
+	}
+	if h, ok := s.(Handleable); ok {
+		for _, r := range h.CommandRoutes() {
+			if r.Topic == "" || !slices.Contains(topics, r.Topic) {
+				p.add(ErrMissingTopic, "command topic %q", r.Topic)
+			}
+			if r.Echo && !slices.Contains(topics, r.StateTopic) {
+				p.add(ErrMissingTopic, "state topic %q", r.StateTopic)
+			}
+		}
+	}
+
+	// Values
+	if c := s.GetDeviceClass(); c != "" && !c.ValidFor(s.GetPlatform()) {
+		p.add(ErrInvalidDeviceClass, "%q is not valid for %q", c, s.GetPlatform())
+	}
+
+	if b, ok := s.(BaseComponent); ok {
+		base := b.GetBaseReference()
+		switch base.EntityCategory {
+		case "", EntityCategoryConfig, EntityCategoryDiagnostic:
+		default:
+			p.add(ErrInvalidValue, "entity category %q", base.EntityCategory)
+		}
+		switch base.AvailabilityMode {
+		case "", "all", "any", "latest":
+		default:
+			p.add(ErrInvalidValue, "availability mode %q", base.AvailabilityMode)
+		}
+	}
+
+	switch c := s.(type) {
+	case *Sensor:
+		validateSensor(p, c)
+	case *Climate:
+		validateClimate(p, c)
+	}
+
+	return errors.Join(p.errs...)
+}
+
+// problems collects what is wrong with a component.
+type problems struct {
+	id   string
+	errs []error
+}
+
+func (p *problems) add(err error, format string, args ...any) {
+	p.errs = append(p.errs, fmt.Errorf("%s: %w: "+format, append([]any{p.id, err}, args...)...))
+}
+
+func validateSensor(p *problems, s *Sensor) {
+	if s.Platform == platform.Sensor && !s.Unit.ValidFor(s.DeviceClass) {
+		p.add(ErrInvalidUnit, "%q is not valid for %q", s.Unit, s.DeviceClass)
+	}
+	if s.State != "" {
+		if !s.State.Valid() {
+			p.add(ErrInvalidValue, "state class %q", s.State)
+		} else if s.Platform != platform.Sensor {
+			p.add(ErrInvalidValue, "state class is only valid for %q", platform.Sensor)
+		}
+	}
+	if len(s.Options) > 0 && s.DeviceClass != device.Enum {
+		p.add(ErrInvalidValue, "options require the %q device class", device.Enum)
+	}
+	if s.DeviceClass == device.Enum && (s.Unit != "" || s.State != "") {
+		p.add(ErrInvalidValue, "%q sensors can't have a unit or state class", device.Enum)
+	}
+	if s.CoalesceState && cap(s.StateCh) == 0 {
+		p.add(ErrInvalidValue, "coalescing states needs a buffered state channel")
+	}
+}
+
+func validateClimate(p *problems, c *Climate) {
+	for _, m := range c.Modes {
+		switch m {
+		case ModeAuto, ModeOff, ModeCool, ModeHeat, ModeDry, ModeFanOnly:
+		default:
+			p.add(ErrInvalidValue, "mode %q", m)
+		}
+	}
+	switch c.TemperatureUnit {
+	case "", TemperatureUnitCelsius, TemperatureUnitFahrenheit:
+	default:
+		p.add(ErrInvalidValue, "temperature unit %q", c.TemperatureUnit)
+	}
+	switch c.Precision {
+	case 0, 0.1, 0.5, 1:
+	default:
+		p.add(ErrInvalidValue, "precision %v", c.Precision)
+	}
+}
+
+// hasKey reports whether the config sets the key, abbreviated or not.
+func hasKey(cfg map[string]json.RawMessage, key string) bool {
+	for _, k := range []string{key, fullKey(key)} {
+		if v, ok := cfg[k]; ok && string(v) != "null" && string(v) != `""` {
+			return true
+		}
+	}
+	return false
+}
+
+// configTopics returns the topics in the config, with the base topic
+// expanded.
+func configTopics(cfg map[string]json.RawMessage) []string {
+	var base string
+	_ = json.Unmarshal(cfg["~"], &base)
+
+	var topics []string
+	for k, v := range cfg {
+		if !strings.HasSuffix(fullKey(k), "_topic") {
+			continue
+		}
+		var t string
+		if err := json.Unmarshal(v, &t); err != nil || t == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(t, "~"); ok {
+			t = base + rest
+		} else if rest, ok := strings.CutSuffix(t, "~"); ok {
+			t = rest + base
+		}
+		topics = append(topics, t)
+	}
+	return topics
+}
@@ -0,0 +1,66 @@
+package component
+
+import (
+	"errors"
+	"testing"
+
+	"lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/class/state"
+	"lib.hemtjan.st/platform"
+	"lib.hemtjan.st/unit"
+)
+
+// misrouted is a switch that handles commands on a topic its config doesn't
+// announce.
+type misrouted struct {
+	Base
+}
+
+func (m *misrouted) CommandRoutes() []CommandRoute {
+	return []CommandRoute{{Topic: "test/other/set"}}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cmp  Settable
+		want error
+	}{
+		{
+			"UnknownKey",
+			&Generic{Raw: []byte(`{"p":"sensor","uniq_id":"test","stat_t":"test/state","bogus":1}`)},
+			ErrUnknownKey,
+		},
+		{
+			"MissingKey",
+			&Generic{Base: Base{Platform: platform.Switch}, Raw: []byte(`{"p":"switch","uniq_id":"test"}`)},
+			ErrMissingKey,
+		},
+		{
+			"MissingTopic",
+			&misrouted{Base{ID: "test", Platform: platform.Switch, CommandTopic: "test/set"}},
+			ErrMissingTopic,
+		},
+		{
+			"InvalidDeviceClass",
+			NewBinarySensor("Door", "door", device.Temperature),
+			ErrInvalidDeviceClass,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cmp); !errors.Is(err, tt.want) {
+				t.Errorf("Validate = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSensorInvalidUnit(t *testing.T) {
+	s, err := NewSensor("Temperature", "temp", device.Temperature, state.Measurement, unit.Volt)
+	if !errors.Is(err, ErrInvalidUnit) {
+		t.Errorf("NewSensor = %v, want %v", err, ErrInvalidUnit)
+	}
+	if s != nil {
+		t.Error("invalid sensor returned")
+	}
+}
@@ -0,0 +1,59 @@
+package device
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// Home Assistant accepts the discovery keys below spelled out in full as
+// well as abbreviated. They are abbreviated before decoding.
+//
+// See: https://github.com/home-assistant/core/blob/dev/homeassistant/components/mqtt/abbreviations.py
+var (
+	configAbbreviations = map[string]string{
+		"device":     "dev",
+		"origin":     "o",
+		"components": "cmps",
+	}
+	infoAbbreviations = map[string]string{
+		"connections":       "cns",
+		"identifiers":       "ids",
+		"manufacturer":      "mf",
+		"model":             "mdl",
+		"model_id":          "mdl_id",
+		"hw_version":        "hw",
+		"sw_version":        "sw",
+		"suggested_area":    "sa",
+		"serial_number":     "sn",
+		"configuration_url": "cu",
+	}
+	originAbbreviations = map[string]string{
+		"sw_version":  "sw",
+		"support_url": "url",
+	}
+)
+
+// abbreviate renames the full keys of a JSON object to their abbreviations,
+// unless the abbreviated key is set as well.
+func abbreviate(data []byte, abbreviations map[string]string) ([]byte, error) {
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &cfg); err != nil || cfg == nil {
+		return data, err
+	}
+
+	renamed := false
+	for _, k := range slices.Sorted(maps.Keys(cfg)) {
+		abbr, ok := abbreviations[k]
+		if _, set := cfg[abbr]; !ok || set {
+			continue
+		}
+		cfg[abbr] = cfg[k]
+		delete(cfg, k)
+		renamed = true
+	}
+	if !renamed {
+		return data, nil
+	}
+	return json.Marshal(cfg)
+}
@@ -1,6 +1,8 @@
 package device
 
 import (
+	"encoding/json"
+	"fmt"
 	"path"
 
 	"lib.hemtjan.st/component"
@@ -15,8 +17,20 @@ type Device struct {
 	Components map[string]component.Settable `json:"cmps,omitempty"`
 }
 
+// DefaultDiscoveryPrefix is the discovery prefix Home Assistant uses unless
+// configured otherwise.
+const DefaultDiscoveryPrefix = "homeassistant"
+
+// DiscoveryTopic is the topic the device config is published on under the
+// default discovery prefix.
 func (d *Device) DiscoveryTopic() string {
-	return path.Join("homeassistant", "device", d.Info.ID, "config")
+	return d.DiscoveryTopicWithPrefix(DefaultDiscoveryPrefix)
+}
+
+// DiscoveryTopicWithPrefix is the topic the device config is published on
+// under the given discovery prefix.
+func (d *Device) DiscoveryTopicWithPrefix(prefix string) string {
+	return path.Join(prefix, "device", d.Info.ID, "config")
 }
 
 func (d *Device) SetComponent(name string, comp component.Settable) error {
@@ -27,3 +41,37 @@ func (d *Device) SetComponent(name string, comp component.Settable) error {
 	d.Components[name] = comp
 	return nil
 }
+
+// UnmarshalJSON decodes a device discovery config, decoding each component
+// into the type registered for its platform with [component.Decode]. Keys
+// may be abbreviated or not.
+func (d *Device) UnmarshalJSON(data []byte) error {
+	data, err := abbreviate(data, configAbbreviations)
+	if err != nil {
+		return err
+	}
+
+	var raw struct {
+		Info       Info                       `json:"dev"`
+		Origin     Origin                     `json:"o"`
+		Components map[string]json.RawMessage `json:"cmps"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	d.Info = raw.Info
+	d.Origin = raw.Origin
+	d.Components = nil
+	for name, data := range raw.Components {
+		cmp, err := component.Decode(data)
+		if err != nil {
+			return fmt.Errorf("component %s: %w", name, err)
+		}
+		if err := d.SetComponent(name, cmp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package device_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/device"
+)
+
+func TestUnmarshalKeySpellings(t *testing.T) {
+	const cfg = `{
+		"device": {
+			"identifiers": ["node", "node_alt"],
+			"name": "Node",
+			"manufacturer": "hemtjanst",
+			"sw_version": "1.0.0",
+			"connections": [["mac", "02:00:00:00:00:01"]],
+			"configuration_url": "http://node.local/"
+		},
+		"origin": {"name": "bibliotek", "sw_version": "2.0.0", "support_url": "https://lib.hemtjan.st"},
+		"components": {
+			"temp": {"platform": "sensor", "unique_id": "node_temp", "state_topic": "node/temp"}
+		}
+	}`
+
+	var dev device.Device
+	if err := json.Unmarshal([]byte(cfg), &dev); err != nil {
+		t.Fatal(err)
+	}
+
+	wantInfo := device.Info{
+		ID:              "node",
+		AdditionalIDs:   []string{"node_alt"},
+		Name:            "Node",
+		Manufacturer:    "hemtjanst",
+		SoftwareVersion: "1.0.0",
+		Connections:     [][2]string{{"mac", "02:00:00:00:00:01"}},
+		URL:             "http://node.local/",
+	}
+	if !reflect.DeepEqual(dev.Info, wantInfo) {
+		t.Errorf("got info %+v, want %+v", dev.Info, wantInfo)
+	}
+	wantOrigin := device.Origin{Name: "bibliotek", SoftwareVersion: "2.0.0", URL: "https://lib.hemtjan.st"}
+	if dev.Origin != wantOrigin {
+		t.Errorf("got origin %+v, want %+v", dev.Origin, wantOrigin)
+	}
+	s, ok := dev.Components["temp"].(*component.Sensor)
+	if !ok {
+		t.Fatalf("component decoded into %T, want *component.Sensor", dev.Components["temp"])
+	}
+	if s.ID != "node_temp" || s.StateTopic != "node/temp" {
+		t.Errorf("got component %+v", s.Base)
+	}
+
+	buf, err := json.Marshal(dev.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if ids := got["ids"]; !reflect.DeepEqual(ids, []any{"node", "node_alt"}) {
+		t.Errorf("got identifiers %v, want a list of both", ids)
+	}
+}
+
+func TestUnmarshalInvalidIDs(t *testing.T) {
+	var info device.Info
+	if err := json.Unmarshal([]byte(`{"ids":42}`), &info); err == nil {
+		t.Error("numeric identifiers accepted")
+	}
+}
+
+func TestCheckUniqueIDs(t *testing.T) {
+	a := &device.Device{Info: device.Info{ID: "a"}}
+	b := &device.Device{Info: device.Info{ID: "b"}}
+	if err := a.SetComponent("temp", component.NewTempSensor("Temperature", "temp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetComponent("temp", component.NewTempSensor("Temperature", "other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.CheckUniqueIDs(a, b); err != nil {
+		t.Fatalf("CheckUniqueIDs = %v, want nil", err)
+	}
+
+	if err := b.SetComponent("dup", component.NewBatterySensor("Battery", "temp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.CheckUniqueIDs(a, b); !errors.Is(err, device.ErrDuplicateID) {
+		t.Errorf("CheckUniqueIDs = %v, want %v", err, device.ErrDuplicateID)
+	}
+}
@@ -0,0 +1,139 @@
+package device_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	classdevice "lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/device"
+	"lib.hemtjan.st/internal/golden"
+)
+
+var goldenDevices = []struct {
+	name string
+	new  func() *device.Device
+}{
+	{"minimal", func() *device.Device {
+		return &device.Device{
+			Info:   device.Info{ID: "minimal", Name: "Minimal"},
+			Origin: device.Origin{Name: "bibliotek"},
+			Components: map[string]component.Settable{
+				"temp": component.NewTempSensor("Temperature", "minimal_temp"),
+			},
+		}
+	}},
+	{"full", func() *device.Device {
+		return &device.Device{
+			Info: device.Info{
+				ID:              "full",
+				Name:            "Full",
+				Manufacturer:    "hemtjanst",
+				Model:           "bibliotek",
+				ModelID:         "bibliotek",
+				SoftwareVersion: "1.0.0",
+				SerialNumber:    "0001",
+				HardwareVersion: "rev2",
+				SuggestedArea:   "Living Room",
+				URL:             "http://full.local/",
+			},
+			Origin: device.Origin{
+				Name:            "bibliotek",
+				SoftwareVersion: "1.0.0",
+				URL:             "https://lib.hemtjan.st",
+			},
+			Components: map[string]component.Settable{
+				"temp":     component.NewTempSensor("Temperature", "full_temp"),
+				"battery":  component.NewBatterySensor("Battery", "full_battery"),
+				"window":   component.NewBinarySensor("Window", "full_window", classdevice.Window),
+				"radiator": component.NewRadiator("full_radiator", "Radiator"),
+			},
+		}
+	}},
+}
+
+func TestGoldenDevices(t *testing.T) {
+	for _, tc := range goldenDevices {
+		t.Run(tc.name, func(t *testing.T) {
+			dev := tc.new()
+			if err := dev.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+
+			got, err := json.MarshalIndent(dev, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.Assert(t, filepath.Join("testdata", tc.name+".json"), got)
+		})
+	}
+}
+
+func TestGenericDeviceRoundTrip(t *testing.T) {
+	for _, tc := range goldenDevices {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var generic struct {
+				Info       device.Info                   `json:"dev"`
+				Origin     device.Origin                 `json:"o"`
+				Components map[string]*component.Generic `json:"cmps"`
+			}
+			if err := json.Unmarshal(want, &generic); err != nil {
+				t.Fatal(err)
+			}
+			for name, cmp := range tc.new().Components {
+				g, ok := generic.Components[name]
+				if !ok {
+					t.Errorf("component %s missing", name)
+					continue
+				}
+				if g.GetID() != cmp.GetID() || g.GetPlatform() != cmp.GetPlatform() {
+					t.Errorf("component %s decoded as %q/%q, want %q/%q", name, g.GetID(), g.GetPlatform(), cmp.GetID(), cmp.GetPlatform())
+				}
+			}
+
+			got, err := json.Marshal(generic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden.AssertJSONEqual(t, got, want)
+		})
+	}
+}
+
+func TestUnmarshalDevice(t *testing.T) {
+	for _, tc := range goldenDevices {
+		t.Run(tc.name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tc.name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var dev device.Device
+			if err := json.Unmarshal(want, &dev); err != nil {
+				t.Fatal(err)
+			}
+			for name, cmp := range tc.new().Components {
+				if got, want := reflect.TypeOf(dev.Components[name]), reflect.TypeOf(cmp); got != want {
+					t.Errorf("component %s decoded into %v, want %v", name, got, want)
+				}
+			}
+
+			got, err := json.MarshalIndent(&dev, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got = append(got, '\n'); !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -1,17 +1,72 @@
 package device
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Info is the device information.
 type Info struct {
-	ID              string   `json:"ids"`
-	Name            string   `json:"name"`
-	Manufacturer    string   `json:"mf,omitempty"`
-	Model           string   `json:"mdl,omitempty"`
-	ModelID         string   `json:"mdl_id,omitempty"`
-	SoftwareVersion string   `json:"sw,omitempty"`
-	SerialNumber    string   `json:"sn,omitempty"`
-	HardwareVersion string   `json:"hw,omitempty"`
-	SuggestedArea   string   `json:"sa,omitempty"`
-	Connections     []string `json:"cns,omitempty"`
-	URL             string   `json:"cu,omitempty"`
-	Via             string   `json:"via_device,omitempty"`
+	ID string `json:"ids"`
+	// AdditionalIDs are identifiers the device has besides ID. The
+	// identifiers are published as a list if there are any.
+	AdditionalIDs   []string    `json:"-"`
+	Name            string      `json:"name"`
+	Manufacturer    string      `json:"mf,omitempty"`
+	Model           string      `json:"mdl,omitempty"`
+	ModelID         string      `json:"mdl_id,omitempty"`
+	SoftwareVersion string      `json:"sw,omitempty"`
+	SerialNumber    string      `json:"sn,omitempty"`
+	HardwareVersion string      `json:"hw,omitempty"`
+	SuggestedArea   string      `json:"sa,omitempty"`
+	Connections     [][2]string `json:"cns,omitempty"`
+	URL             string      `json:"cu,omitempty"`
+	Via             string      `json:"via_device,omitempty"`
+}
+
+// info has the fields of Info without its methods.
+type info Info
+
+func (i Info) MarshalJSON() ([]byte, error) {
+	var ids any = i.ID
+	if len(i.AdditionalIDs) > 0 {
+		ids = append([]string{i.ID}, i.AdditionalIDs...)
+	}
+	return json.Marshal(struct {
+		IDs any `json:"ids"`
+		info
+	}{ids, info(i)})
+}
+
+// UnmarshalJSON decodes the device information. The identifiers may be a
+// string or a list, and keys may be abbreviated or not.
+func (i *Info) UnmarshalJSON(data []byte) error {
+	data, err := abbreviate(data, infoAbbreviations)
+	if err != nil {
+		return err
+	}
+
+	v := struct {
+		IDs json.RawMessage `json:"ids"`
+		*info
+	}{info: (*info)(i)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	i.ID, i.AdditionalIDs = "", nil
+	if len(v.IDs) == 0 || string(v.IDs) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(v.IDs, &i.ID); err == nil {
+		return nil
+	}
+	var ids []string
+	if err := json.Unmarshal(v.IDs, &ids); err != nil {
+		return fmt.Errorf("identifiers must be a string or a list of strings: %s", v.IDs)
+	}
+	if len(ids) > 0 {
+		i.ID, i.AdditionalIDs = ids[0], ids[1:]
+	}
+	return nil
 }
@@ -1,5 +1,7 @@
 package device
 
+import "encoding/json"
+
 // Origin is the origin information.
 //
 // It represents where a device is coming from.
@@ -8,3 +10,14 @@ type Origin struct {
 	SoftwareVersion string `json:"sw,omitempty"`
 	URL             string `json:"url,omitempty"`
 }
+
+// UnmarshalJSON decodes the origin information, with keys abbreviated or
+// not.
+func (o *Origin) UnmarshalJSON(data []byte) error {
+	data, err := abbreviate(data, originAbbreviations)
+	if err != nil {
+		return err
+	}
+	type origin Origin
+	return json.Unmarshal(data, (*origin)(o))
+}
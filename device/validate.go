@@ -0,0 +1,59 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"lib.hemtjan.st/component"
+)
+
+// ErrDuplicateID is returned when two components share a unique ID.
+var ErrDuplicateID = errors.New("duplicate unique ID")
+
+// Validate checks the device and its components for configuration Home
+// Assistant would reject. All problems found are returned joined together.
+func (d *Device) Validate() error {
+	var errs []error
+	if d.Info.ID == "" {
+		errs = append(errs, fmt.Errorf("device: %w: %q", component.ErrMissingKey, "ids"))
+	}
+	if d.Origin.Name == "" {
+		errs = append(errs, fmt.Errorf("device %s: %w: %q", d.Info.ID, component.ErrMissingKey, "o.name"))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(d.Components)) {
+		if err := component.Validate(d.Components[name]); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: component %s: %w", d.Info.ID, name, err))
+		}
+	}
+
+	errs = append(errs, CheckUniqueIDs(d))
+	return errors.Join(errs...)
+}
+
+// CheckUniqueIDs checks that no two components of the devices share a unique
+// ID, as Home Assistant ignores all but the first of them.
+func CheckUniqueIDs(devs ...*Device) error {
+	type owner struct {
+		device, component string
+	}
+
+	var errs []error
+	seen := map[string]owner{}
+	for _, d := range devs {
+		for _, name := range slices.Sorted(maps.Keys(d.Components)) {
+			id := d.Components[name].GetID()
+			if id == "" {
+				continue
+			}
+			if o, ok := seen[id]; ok {
+				errs = append(errs, fmt.Errorf("device %s: component %s: %w: %q is also used by device %s component %s", d.Info.ID, name, ErrDuplicateID, id, o.device, o.component))
+				continue
+			}
+			seen[id] = owner{d.Info.ID, name}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,388 @@
+// Package discovery tracks the devices and components announced through
+// MQTT discovery on a broker.
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/device"
+	"lib.hemtjan.st/platform"
+	"lib.hemtjan.st/server"
+)
+
+// Subscriber subscribes to MQTT topics. [*server.Server] implements it.
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic string, handler server.MessageHandler) (server.UnsubscribeFunc, error)
+}
+
+// Config is a discovery config announced on the broker.
+type Config struct {
+	Topic string
+
+	// Platform, NodeID and ObjectID are taken from the topic. Platform is
+	// empty for device discovery configs, and NodeID is empty unless the
+	// topic has one.
+	Platform platform.Type
+	NodeID   string
+	ObjectID string
+
+	// Device holds the decoded config. A single-component config is held
+	// as a device with the component under its object ID, and the device
+	// information the config has, if any.
+	Device *device.Device
+}
+
+// EventType is what happened to a config.
+type EventType int
+
+const (
+	Added EventType = iota
+	Updated
+	Removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event reports a config being added, updated or removed.
+type Event struct {
+	Type EventType
+	// Config is the new config, or the last one if it was removed.
+	Config Config
+	// Previous is the config an update replaced.
+	Previous Config
+}
+
+// Option configures a [Watcher].
+type Option func(*Watcher)
+
+// DefaultQueueSize is how many events a [Watcher] queues by default.
+const DefaultQueueSize = 1024
+
+// WithDiscoveryPrefix sets the prefix to watch for discovery configs under.
+// It defaults to [device.DefaultDiscoveryPrefix].
+func WithDiscoveryPrefix(prefix string) Option {
+	return func(w *Watcher) {
+		w.prefix = prefix
+	}
+}
+
+// WithQueueSize sets how many events are queued while the events channel
+// isn't read. It defaults to [DefaultQueueSize].
+func WithQueueSize(n int) Option {
+	return func(w *Watcher) {
+		w.queueSize = n
+	}
+}
+
+// Watcher keeps a live registry of the discovery configs announced on the
+// broker.
+type Watcher struct {
+	sub       Subscriber
+	logger    *slog.Logger
+	prefix    string
+	queueSize int
+
+	unsubscribe []server.UnsubscribeFunc
+
+	mu      sync.Mutex
+	configs map[string]entry
+	queue   []Event
+	wake    chan struct{}
+	events  chan Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// entry is a tracked config and the payload it was decoded from.
+type entry struct {
+	cfg     Config
+	payload []byte
+}
+
+// NewWatcher creates a watcher that subscribes through sub once started.
+func NewWatcher(log *slog.Logger, sub Subscriber, opts ...Option) *Watcher {
+	w := &Watcher{
+		sub:       sub,
+		logger:    log,
+		prefix:    device.DefaultDiscoveryPrefix,
+		queueSize: DefaultQueueSize,
+		configs:   map[string]entry{},
+		wake:      make(chan struct{}, 1),
+		events:    make(chan Event),
+		done:      make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Start subscribes to the discovery topics. Configs already retained on the
+// broker are reported as added.
+//
+// Only config topics, with or without a node ID, are subscribed to, so the
+// state updates published under the prefix aren't received.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.wg.Add(1)
+	go w.run()
+
+	var errs []error
+	for _, filter := range []string{
+		path.Join(w.prefix, "+", "+", "config"),
+		path.Join(w.prefix, "+", "+", "+", "config"),
+	} {
+		unsubscribe, err := w.sub.Subscribe(ctx, filter, w.handle)
+		if unsubscribe != nil {
+			w.unsubscribe = append(w.unsubscribe, unsubscribe)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stop unsubscribes from the discovery topics and closes the events channel.
+func (w *Watcher) Stop(ctx context.Context) error {
+	var errs []error
+	for _, unsubscribe := range w.unsubscribe {
+		if err := unsubscribe(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	w.mu.Lock()
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Events returns the channel events are delivered on, in the order they
+// happened. Events are queued until they are read, so the channel doesn't
+// have to be read at all. It is closed when the watcher stops.
+//
+// Queued events for the same topic are merged, so a reader that falls
+// behind sees the net change of each config. If more configs than the queue
+// size change before the channel is read, the oldest events are dropped.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Configs returns the configs currently announced, sorted by topic.
+func (w *Watcher) Configs() []Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cfgs := make([]Config, 0, len(w.configs))
+	for _, topic := range slices.Sorted(maps.Keys(w.configs)) {
+		cfgs = append(cfgs, w.configs[topic].cfg)
+	}
+	return cfgs
+}
+
+// Config returns the config announced on a topic.
+func (w *Watcher) Config(topic string) (Config, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	e, ok := w.configs[topic]
+	return e.cfg, ok
+}
+
+// handle updates the registry with a message received on a discovery topic.
+func (w *Watcher) handle(m *server.Message) {
+	cfg, ok := w.parseTopic(m.Topic)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Configs are often republished unchanged, such as when Home
+	// Assistant restarts.
+	prev, known := w.configs[m.Topic]
+	if known && bytes.Equal(prev.payload, m.Payload) {
+		return
+	}
+
+	if len(m.Payload) == 0 {
+		if known {
+			delete(w.configs, m.Topic)
+			w.emit(Event{Type: Removed, Config: prev.cfg})
+		}
+		return
+	}
+
+	dev, err := w.decode(cfg, m.Payload)
+	if err != nil {
+		w.logger.Warn("unable to decode discovery config", slog.String("topic", m.Topic), slog.String("error", err.Error()))
+		return
+	}
+	cfg.Device = dev
+
+	w.configs[m.Topic] = entry{cfg: cfg, payload: bytes.Clone(m.Payload)}
+	if known {
+		w.emit(Event{Type: Updated, Config: cfg, Previous: prev.cfg})
+	} else {
+		w.emit(Event{Type: Added, Config: cfg})
+	}
+}
+
+// parseTopic takes the platform, node ID and object ID from a discovery
+// topic.
+func (w *Watcher) parseTopic(topic string) (Config, bool) {
+	rest, ok := strings.CutPrefix(topic, w.prefix+"/")
+	if !ok {
+		return Config{}, false
+	}
+
+	rest, ok = strings.CutSuffix(rest, "/config")
+	if !ok {
+		return Config{}, false
+	}
+
+	cfg := Config{Topic: topic}
+	switch parts := strings.Split(rest, "/"); len(parts) {
+	case 2:
+		cfg.Platform, cfg.ObjectID = platform.Type(parts[0]), parts[1]
+	case 3:
+		cfg.Platform, cfg.NodeID, cfg.ObjectID = platform.Type(parts[0]), parts[1], parts[2]
+	default:
+		return Config{}, false
+	}
+
+	if cfg.Platform == "device" {
+		if cfg.NodeID != "" {
+			return Config{}, false
+		}
+		cfg.Platform = ""
+	}
+	return cfg, true
+}
+
+// decode decodes a discovery config. Components removed from a device config
+// are left out.
+func (w *Watcher) decode(cfg Config, payload []byte) (*device.Device, error) {
+	if cfg.Platform == "" {
+		dev := &device.Device{}
+		if err := json.Unmarshal(payload, dev); err != nil {
+			return nil, err
+		}
+		maps.DeleteFunc(dev.Components, func(_ string, c component.Settable) bool {
+			_, removed := c.(component.Removed)
+			return removed
+		})
+		return dev, nil
+	}
+
+	cmp, err := component.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// The device information is optional, so a config is still tracked if
+	// it can't be decoded.
+	var meta device.Device
+	if err := json.Unmarshal(payload, &meta); err != nil {
+		w.logger.Debug("unable to decode device information", slog.String("topic", cfg.Topic), slog.String("error", err.Error()))
+	}
+
+	return &device.Device{
+		Info:       meta.Info,
+		Origin:     meta.Origin,
+		Components: map[string]component.Settable{cfg.ObjectID: cmp},
+	}, nil
+}
+
+// emit queues an event for delivery, merging it with an event queued for the
+// same topic. It must be called with mu held.
+func (w *Watcher) emit(e Event) {
+	i := slices.IndexFunc(w.queue, func(q Event) bool { return q.Config.Topic == e.Config.Topic })
+	if i >= 0 {
+		queued := w.queue[i]
+		w.queue = slices.Delete(w.queue, i, i+1)
+
+		switch {
+		case queued.Type == Added && e.Type == Removed:
+			// The config was never reported.
+			return
+		case queued.Type == Added:
+			e = Event{Type: Added, Config: e.Config}
+		case queued.Type == Removed && e.Type == Added:
+			e = Event{Type: Updated, Config: e.Config, Previous: queued.Config}
+		case queued.Type == Updated && e.Type == Updated:
+			e.Previous = queued.Previous
+		}
+	}
+
+	if len(w.queue) >= max(w.queueSize, 1) {
+		dropped := w.queue[0]
+		w.queue = w.queue[1:]
+		w.logger.Warn("dropping discovery event, events aren't being read", slog.String("topic", dropped.Config.Topic), slog.String("event", dropped.Type.String()))
+	}
+
+	w.queue = append(w.queue, e)
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued events on the events channel until the watcher stops.
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	defer close(w.events)
+
+	for {
+		w.mu.Lock()
+		var next *Event
+		if len(w.queue) > 0 {
+			next = &w.queue[0]
+			w.queue = w.queue[1:]
+		}
+		w.mu.Unlock()
+
+		if next == nil {
+			select {
+			case <-w.wake:
+				continue
+			case <-w.done:
+				return
+			}
+		}
+
+		select {
+		case w.events <- *next:
+		case <-w.done:
+			return
+		}
+	}
+}
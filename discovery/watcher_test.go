@@ -0,0 +1,253 @@
+package discovery_test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"lib.hemtjan.st/bibliotektest"
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/discovery"
+	"lib.hemtjan.st/server"
+)
+
+func startWatcher(t *testing.T, h *bibliotektest.Harness) *discovery.Watcher {
+	t.Helper()
+
+	w := discovery.NewWatcher(slog.New(slog.DiscardHandler), h.Server)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := w.Stop(context.Background()); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	})
+	return w
+}
+
+func nextEvent(t *testing.T, w *discovery.Watcher) discovery.Event {
+	t.Helper()
+
+	select {
+	case e := <-w.Events():
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return discovery.Event{}
+}
+
+func publish(h *bibliotektest.Harness, topic, payload string) {
+	h.Broker.Publish(server.Message{Topic: topic, Payload: []byte(payload), Retain: true})
+}
+
+func TestWatcherComponent(t *testing.T) {
+	h := bibliotektest.New(t)
+	publish(h, "homeassistant/sensor/node/temp/config",
+		`{"p":"sensor","uniq_id":"temp","stat_t":"node/temp","dev":{"ids":"node","name":"Node"}}`)
+
+	w := startWatcher(t, h)
+
+	e := nextEvent(t, w)
+	if e.Type != discovery.Added {
+		t.Fatalf("got %s event, want added", e.Type)
+	}
+	cfg := e.Config
+	if cfg.Platform != "sensor" || cfg.NodeID != "node" || cfg.ObjectID != "temp" {
+		t.Errorf("got platform %q, node ID %q, object ID %q", cfg.Platform, cfg.NodeID, cfg.ObjectID)
+	}
+	if cfg.Device.Info.ID != "node" {
+		t.Errorf("got device ID %q, want %q", cfg.Device.Info.ID, "node")
+	}
+	s, ok := cfg.Device.Components["temp"].(*component.Sensor)
+	if !ok {
+		t.Fatalf("component decoded into %T, want *component.Sensor", cfg.Device.Components["temp"])
+	}
+	if s.StateTopic != "node/temp" {
+		t.Errorf("got state topic %q, want %q", s.StateTopic, "node/temp")
+	}
+
+	publish(h, "homeassistant/light/lamp/config", `{"p":"light","uniq_id":"lamp","cmd_t":"lamp/set"}`)
+	e = nextEvent(t, w)
+	if _, ok := e.Config.Device.Components["lamp"].(*component.Generic); !ok {
+		t.Errorf("unknown platform decoded into %T, want *component.Generic", e.Config.Device.Components["lamp"])
+	}
+
+	publish(h, "homeassistant/sensor/node/temp/config", "")
+	e = nextEvent(t, w)
+	if e.Type != discovery.Removed || e.Config.Topic != "homeassistant/sensor/node/temp/config" {
+		t.Errorf("got %s event for %s, want removed", e.Type, e.Config.Topic)
+	}
+
+	cfgs := w.Configs()
+	if len(cfgs) != 1 || cfgs[0].ObjectID != "lamp" {
+		t.Errorf("got configs %+v, want only lamp", cfgs)
+	}
+}
+
+func TestWatcherDevice(t *testing.T) {
+	h := bibliotektest.New(t)
+	w := startWatcher(t, h)
+
+	const topic = "homeassistant/device/dev1/config"
+	const cfg = `{"dev":{"ids":"dev1","name":"Device"},"o":{"name":"test"},"cmps":{` +
+		`"temp":{"p":"sensor","uniq_id":"dev1_temp","stat_t":"dev1/temp"},` +
+		`"radiator":{"p":"climate","uniq_id":"dev1_radiator","modes":["heat"]}}}`
+	publish(h, topic, cfg)
+
+	e := nextEvent(t, w)
+	if e.Type != discovery.Added || e.Config.Platform != "" || e.Config.ObjectID != "dev1" {
+		t.Fatalf("got %s event for %+v", e.Type, e.Config)
+	}
+	if _, ok := e.Config.Device.Components["radiator"].(*component.Climate); !ok {
+		t.Errorf("radiator decoded into %T, want *component.Climate", e.Config.Device.Components["radiator"])
+	}
+
+	// Republished configs don't cause events.
+	publish(h, topic, cfg)
+
+	publish(h, topic, `{"dev":{"ids":"dev1","name":"Device"},"o":{"name":"test"},"cmps":{`+
+		`"temp":{"p":"sensor","uniq_id":"dev1_temp","stat_t":"dev1/temp"},`+
+		`"radiator":{"p":"climate"}}}`)
+	e = nextEvent(t, w)
+	if e.Type != discovery.Updated {
+		t.Fatalf("got %s event, want updated", e.Type)
+	}
+	if _, ok := e.Config.Device.Components["radiator"]; ok {
+		t.Error("removed component still in device")
+	}
+	if _, ok := e.Previous.Device.Components["radiator"]; !ok {
+		t.Error("removed component missing from previous device")
+	}
+
+	publish(h, topic, "")
+	if e = nextEvent(t, w); e.Type != discovery.Removed {
+		t.Errorf("got %s event, want removed", e.Type)
+	}
+	if _, ok := w.Config(topic); ok {
+		t.Error("removed device still in registry")
+	}
+}
+
+func TestWatcherKeySpellings(t *testing.T) {
+	h := bibliotektest.New(t)
+	w := startWatcher(t, h)
+
+	publish(h, "homeassistant/sensor/node/temp/config", `{"platform":"sensor","unique_id":"temp",`+
+		`"state_topic":"node/temp","device":{"identifiers":["node","node_alt"],"name":"Node"}}`)
+	e := nextEvent(t, w)
+	if e.Type != discovery.Added {
+		t.Fatalf("got %s event, want added", e.Type)
+	}
+	if info := e.Config.Device.Info; info.ID != "node" || info.Name != "Node" {
+		t.Errorf("got device %+v", info)
+	}
+	s, ok := e.Config.Device.Components["temp"].(*component.Sensor)
+	if !ok {
+		t.Fatalf("component decoded into %T, want *component.Sensor", e.Config.Device.Components["temp"])
+	}
+	if s.ID != "temp" || s.StateTopic != "node/temp" {
+		t.Errorf("got component %+v", s.Base)
+	}
+
+	// Topics under the prefix that aren't discovery configs are ignored.
+	publish(h, "homeassistant/status", "online")
+	publish(h, "homeassistant/sensor/node/temp/state", "21")
+	select {
+	case e := <-w.Events():
+		t.Errorf("got %s event for %s", e.Type, e.Config.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatcherQueue(t *testing.T) {
+	h := bibliotektest.New(t)
+	w := discovery.NewWatcher(slog.New(slog.DiscardHandler), h.Server, discovery.WithQueueSize(2))
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Stop(context.Background()) })
+
+	topic := func(id string) string { return "homeassistant/sensor/" + id + "/config" }
+	config := func(id, state string) string {
+		return `{"p":"sensor","uniq_id":"` + id + `","stat_t":"` + state + `"}`
+	}
+
+	// The first event is taken off the queue while it waits to be read.
+	publish(h, topic("first"), config("first", "first"))
+	time.Sleep(20 * time.Millisecond)
+
+	publish(h, topic("a"), config("a", "a/1"))
+	publish(h, topic("a"), config("a", "a/2"))
+	publish(h, topic("b"), config("b", "b"))
+	publish(h, topic("b"), "")
+	publish(h, topic("c"), config("c", "c"))
+	publish(h, topic("d"), config("d", "d"))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := w.Config(topic("d")); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("configs not received")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Events for a were merged and then dropped as the oldest, and b was
+	// removed before it was reported.
+	var got []string
+	for range 3 {
+		e := nextEvent(t, w)
+		got = append(got, e.Type.String()+" "+e.Config.ObjectID)
+	}
+	if want := []string{"added first", "added c", "added d"}; !slices.Equal(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+
+	cfg, ok := w.Config(topic("a"))
+	if !ok {
+		t.Fatal("config of dropped event missing from registry")
+	}
+	if s := cfg.Device.Components["a"].(*component.Sensor); s.StateTopic != "a/2" {
+		t.Errorf("got state topic %q, want the updated one", s.StateTopic)
+	}
+}
+
+// recorder records the topics subscribed to.
+type recorder struct {
+	topics       []string
+	unsubscribed int
+}
+
+func (r *recorder) Subscribe(ctx context.Context, topic string, handler server.MessageHandler) (server.UnsubscribeFunc, error) {
+	r.topics = append(r.topics, topic)
+	return func(context.Context) error {
+		r.unsubscribed++
+		return nil
+	}, nil
+}
+
+func TestWatcherSubscriptions(t *testing.T) {
+	r := &recorder{}
+	w := discovery.NewWatcher(slog.New(slog.DiscardHandler), r, discovery.WithDiscoveryPrefix("ha"))
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// State topics under the prefix aren't subscribed to.
+	if want := []string{"ha/+/+/config", "ha/+/+/+/config"}; !slices.Equal(r.topics, want) {
+		t.Errorf("subscribed to %q, want %q", r.topics, want)
+	}
+
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.unsubscribed != len(r.topics) {
+		t.Errorf("unsubscribed from %d of %d topics", r.unsubscribed, len(r.topics))
+	}
+}
@@ -2,9 +2,13 @@ module lib.hemtjan.st
 
 go 1.24
 
-require github.com/eclipse/paho.golang v0.22.0
+require (
+	github.com/eclipse/paho.golang v0.22.0
+	github.com/eclipse/paho.mqtt.golang v1.5.0
+)
 
 require (
 	github.com/gorilla/websocket v1.5.3 // indirect
 	golang.org/x/net v0.38.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
 )
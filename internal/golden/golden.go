@@ -0,0 +1,49 @@
+// Package golden compares test output to golden files in testdata.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// Assert compares got to the golden file, or updates the file with -update.
+func Assert(t testing.TB, file string, got []byte) {
+	t.Helper()
+
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs:\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// AssertJSONEqual compares two JSON documents, ignoring formatting.
+func AssertJSONEqual(t testing.TB, got, want []byte) {
+	t.Helper()
+
+	var g, w bytes.Buffer
+	if err := json.Compact(&g, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Compact(&w, want); err != nil {
+		t.Fatal(err)
+	}
+	if g.String() != w.String() {
+		t.Errorf("got:\n%s\nwant:\n%s", g.String(), w.String())
+	}
+}
@@ -0,0 +1,21 @@
+// Package topic matches MQTT topics against subscription filters.
+package topic
+
+import "strings"
+
+// Match reports whether the topic matches the subscription filter, which may
+// contain the + and # wildcards.
+func Match(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fs {
+		switch {
+		case f == "#":
+			return true
+		case i >= len(ts):
+			return false
+		case f != "+" && f != ts[i]:
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
@@ -3,7 +3,14 @@
 type Type string
 
 const (
+	Button       Type = "button"
 	Climate      Type = "climate"
+	Cover        Type = "cover"
+	Event        Type = "event"
+	Number       Type = "number"
 	Sensor       Type = "sensor"
 	SensorBinary Type = "binary_sensor"
+	Switch       Type = "switch"
+	Update       Type = "update"
+	Valve        Type = "valve"
 )
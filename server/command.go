@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"lib.hemtjan.st/component"
+)
+
+// commandQueue returns a handler that queues the commands of a topic, and
+// starts delivering them to the application.
+//
+// The handler never blocks the MQTT client for longer than the queue's
+// overflow policy allows, so a component whose commands aren't read can't
+// hold up the others. Commands are acknowledged once they have been
+// delivered or dropped.
+func (s *Server) commandQueue(ctx context.Context, mc *managedComponent, q component.CommandQueue, deliver func(context.Context, component.Command) bool) MessageHandler {
+	size := q.Size
+	if size <= 0 {
+		size = component.DefaultCommandQueueSize
+	}
+	queue := make(chan *Message, size)
+
+	// closed is set once the queue is no longer read, after which
+	// commands are abandoned rather than queued.
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+
+	mc.wg.Add(1)
+	go func() {
+		defer mc.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				defer mu.Unlock()
+				closed = true
+				for {
+					select {
+					case m := <-queue:
+						s.abandonCommand(m)
+					default:
+						return
+					}
+				}
+			case m := <-queue:
+				if deliver(ctx, s.command(m)) {
+					m.ack()
+				} else {
+					s.abandonCommand(m)
+				}
+			}
+		}
+	}()
+
+	return func(m *Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			s.abandonCommand(m)
+			return
+		}
+
+		select {
+		case queue <- m:
+			return
+		default:
+		}
+
+		switch q.Overflow {
+		case component.DropNewest:
+			s.dropCommand(m, "queue full, dropping newest")
+		case component.Block:
+			var expired <-chan time.Time
+			if q.Timeout > 0 {
+				timer := time.NewTimer(q.Timeout)
+				defer timer.Stop()
+				expired = timer.C
+			}
+			select {
+			case queue <- m:
+			case <-ctx.Done():
+				s.abandonCommand(m)
+			case <-expired:
+				s.dropCommand(m, "queue full, timed out")
+			}
+		default:
+			// Only the handler adds to the queue, so there is room once
+			// the oldest command has been dropped, unless it was just
+			// delivered.
+			select {
+			case old := <-queue:
+				s.dropCommand(old, "queue full, dropping oldest")
+			default:
+			}
+			queue <- m
+		}
+	}
+}
+
+// command converts a received message to a command.
+func (s *Server) command(m *Message) component.Command {
+	cmd := component.Command{
+		Topic:           m.Topic,
+		Payload:         m.Payload,
+		QoS:             m.QoS,
+		ResponseTopic:   m.ResponseTopic,
+		CorrelationData: m.CorrelationData,
+		UserProperties:  m.UserProperties,
+	}
+
+	cmd.Reply = func(ctx context.Context, payload []byte) error {
+		if cmd.ResponseTopic == "" {
+			return nil
+		}
+		return s.publishMessage(ctx, &Message{
+			QoS:             m.QoS,
+			Topic:           cmd.ResponseTopic,
+			Payload:         payload,
+			CorrelationData: cmd.CorrelationData,
+		})
+	}
+
+	return cmd
+}
+
+// toChannel delivers commands to a command channel.
+func toChannel(c component.CommandChannel) func(context.Context, component.Command) bool {
+	return func(ctx context.Context, cmd component.Command) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case c.Channel <- string(cmd.Payload):
+			return true
+		}
+	}
+}
+
+// toHandler delivers commands to a command handler, reporting the errors it
+// returns. Accepted commands are echoed to the state topic if the route asks
+// for it.
+func (s *Server) toHandler(r component.CommandRoute) func(context.Context, component.Command) bool {
+	return func(ctx context.Context, cmd component.Command) bool {
+		err := r.Handler(ctx, cmd)
+		if err == nil {
+			if r.Echo && r.StateTopic != "" {
+				s.publishUpdate(ctx, component.UpdateChannel{Topic: r.StateTopic, Retain: r.Retain}, string(cmd.Payload))
+			}
+			return true
+		}
+
+		s.logger.Error("command failed", slog.String("topic", cmd.Topic), slog.String("error", err.Error()))
+		if r.ErrorTopic != "" {
+			if perr := s.Publish(ctx, r.ErrorTopic, 1, []byte(err.Error())); perr != nil {
+				s.logger.Error("unable to publish command error", slog.String("topic", r.ErrorTopic), slog.String("error", perr.Error()))
+			}
+		}
+		return true
+	}
+}
+
+// dropCommand acknowledges a command the queue has no room for.
+func (s *Server) dropCommand(m *Message, reason string) {
+	s.logger.Warn("dropped command", slog.String("topic", m.Topic), slog.String("reason", reason))
+	m.ack()
+}
+
+// abandonCommand acknowledges a command that won't be delivered as its
+// component was removed. Commands abandoned as the server stops are left
+// unacknowledged, so that the broker delivers them again.
+func (s *Server) abandonCommand(m *Message) {
+	select {
+	case <-s.stopped:
+	default:
+		m.ack()
+	}
+}
@@ -0,0 +1,227 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"lib.hemtjan.st/bibliotektest"
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/device"
+	"lib.hemtjan.st/platform"
+	"lib.hemtjan.st/server"
+)
+
+// testSwitch is a switch whose commands are read from a channel or passed to
+// a handler, as an application's own components would.
+type testSwitch struct {
+	component.Base
+	commands chan string
+	handler  component.CommandHandler
+}
+
+func newSwitch() *testSwitch {
+	return &testSwitch{
+		Base: component.Base{
+			Name:         "Switch",
+			ID:           "switch",
+			Platform:     platform.Switch,
+			CommandTopic: "test/switch/set",
+			StateTopic:   "test/switch/state",
+		},
+	}
+}
+
+func (s *testSwitch) CommandChannels() []component.CommandChannel {
+	if s.commands == nil {
+		return nil
+	}
+	return []component.CommandChannel{{Topic: s.CommandTopic, Channel: s.commands}}
+}
+
+func (s *testSwitch) CommandRoutes() []component.CommandRoute {
+	if s.handler == nil {
+		return nil
+	}
+	return []component.CommandRoute{{Topic: s.CommandTopic, Handler: s.handler}}
+}
+
+// addSwitch adds a device with the switch to the server.
+func addSwitch(t *testing.T, h *bibliotektest.Harness, sw *testSwitch) {
+	t.Helper()
+
+	dev := &device.Device{
+		Info:   device.Info{ID: "test", Name: "Test"},
+		Origin: device.Origin{Name: "bibliotektest"},
+	}
+	if err := dev.SetComponent(sw.ID, sw); err != nil {
+		t.Fatal(err)
+	}
+	h.AddDevice(dev)
+}
+
+// expectUnacked waits until the server has left exactly the payloads
+// unacknowledged.
+func expectUnacked(t *testing.T, h *bibliotektest.Harness, payloads ...string) {
+	t.Helper()
+
+	var got []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		got = got[:0]
+		for _, m := range h.Conn.Unacked() {
+			got = append(got, string(m.Payload))
+		}
+		if slices.Equal(got, payloads) {
+			return
+		}
+	}
+	t.Fatalf("got %q unacknowledged, want %q", got, payloads)
+}
+
+func TestCommandAcknowledged(t *testing.T) {
+	h := bibliotektest.New(t)
+	sw := newSwitch()
+	sw.commands = make(chan string)
+	addSwitch(t, h, sw)
+
+	// A command is acknowledged once the application has read it.
+	h.SendCommand(sw.CommandTopic, "ON")
+	expectUnacked(t, h, "ON")
+	time.Sleep(20 * time.Millisecond)
+	expectUnacked(t, h, "ON")
+	select {
+	case cmd := <-sw.commands:
+		if cmd != "ON" {
+			t.Errorf("got command %q, want ON", cmd)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("command not delivered")
+	}
+	expectUnacked(t, h)
+
+	// Commands that haven't been read when the server stops are left for
+	// the broker to deliver again.
+	h.SendCommand(sw.CommandTopic, "OFF")
+	expectUnacked(t, h, "OFF")
+	within(t, h.Stop)
+	expectUnacked(t, h, "OFF")
+}
+
+func TestCommandOverflow(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		queue component.CommandQueue
+		want  []string
+	}{
+		{"DropOldest", component.CommandQueue{Size: 2}, []string{"1", "3", "4"}},
+		{"DropNewest", component.CommandQueue{Size: 2, Overflow: component.DropNewest}, []string{"1", "2", "3"}},
+		{"Block", component.CommandQueue{Size: 2, Overflow: component.Block}, []string{"1", "2", "3", "4"}},
+		{"BlockTimeout", component.CommandQueue{Size: 2, Overflow: component.Block, Timeout: 10 * time.Millisecond}, []string{"1", "2", "3"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := bibliotektest.New(t)
+
+			got := make(chan string, 10)
+			release := make(chan struct{})
+			releaseOnce := sync.OnceFunc(func() { close(release) })
+			t.Cleanup(releaseOnce)
+			sw := newSwitch()
+			sw.CommandQueue = tt.queue
+			sw.handler = func(ctx context.Context, cmd component.Command) error {
+				got <- string(cmd.Payload)
+				<-release
+				return nil
+			}
+			addSwitch(t, h, sw)
+
+			// The handler is busy with the first command while the
+			// others fill the queue.
+			h.SendCommand(sw.CommandTopic, "1")
+			if cmd := <-got; cmd != "1" {
+				t.Fatalf("got command %q, want 1", cmd)
+			}
+			for _, cmd := range []string{"2", "3", "4"} {
+				h.SendCommand(sw.CommandTopic, cmd)
+			}
+
+			// Dropped commands are acknowledged straight away.
+			expectUnacked(t, h, tt.want...)
+
+			releaseOnce()
+			handled := []string{"1"}
+			for len(handled) < len(tt.want) {
+				select {
+				case cmd := <-got:
+					handled = append(handled, cmd)
+				case <-time.After(time.Second):
+					t.Fatalf("got commands %q, want %q", handled, tt.want)
+				}
+			}
+			if !slices.Equal(handled, tt.want) {
+				t.Errorf("got commands %q, want %q", handled, tt.want)
+			}
+			expectUnacked(t, h)
+		})
+	}
+}
+
+func TestCommandReply(t *testing.T) {
+	h := bibliotektest.New(t)
+	sw := newSwitch()
+	sw.handler = func(ctx context.Context, cmd component.Command) error {
+		return cmd.Reply(ctx, []byte("pong"))
+	}
+	addSwitch(t, h, sw)
+
+	h.Broker.Publish(server.Message{
+		Topic:           sw.CommandTopic,
+		Payload:         []byte("ping"),
+		QoS:             1,
+		ResponseTopic:   "test/reply",
+		CorrelationData: []byte("42"),
+	})
+	m := h.NextMessage("test/reply")
+	if string(m.Payload) != "pong" || string(m.CorrelationData) != "42" {
+		t.Errorf("got reply %q with correlation data %q, want pong with 42", m.Payload, m.CorrelationData)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	h := bibliotektest.New(t)
+	sw := newSwitch()
+	sw.CommandErrorTopic = "test/switch/error"
+	sw.handler = func(ctx context.Context, cmd component.Command) error {
+		return fmt.Errorf("unsupported command %q", cmd.Payload)
+	}
+	addSwitch(t, h, sw)
+
+	h.SendCommand(sw.CommandTopic, "TOGGLE")
+	h.ExpectState(sw.CommandErrorTopic, `unsupported command "TOGGLE"`)
+	expectUnacked(t, h)
+}
+
+func TestCommandEcho(t *testing.T) {
+	h := bibliotektest.New(t)
+	sw := newSwitch()
+	sw.EchoCommands = true
+	sw.handler = func(ctx context.Context, cmd component.Command) error {
+		if string(cmd.Payload) != "ON" && string(cmd.Payload) != "OFF" {
+			return fmt.Errorf("unsupported command %q", cmd.Payload)
+		}
+		return nil
+	}
+	addSwitch(t, h, sw)
+
+	// Only accepted commands are echoed.
+	h.SendCommand(sw.CommandTopic, "TOGGLE")
+	h.SendCommand(sw.CommandTopic, "ON")
+	h.ExpectState(sw.StateTopic, "ON")
+	for _, m := range h.Broker.Messages() {
+		if m.Topic == sw.StateTopic && string(m.Payload) != "ON" {
+			t.Errorf("got %q echoed", m.Payload)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"lib.hemtjan.st/component"
+)
+
+// managedDevice tracks what the server set up for a device's components.
+type managedDevice struct {
+	components map[string]*managedComponent
+}
+
+// managedComponent holds the goroutines and subscriptions of a component.
+type managedComponent struct {
+	cmp         component.Settable
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	updates     []component.UpdateChannel
+	unsubscribe []UnsubscribeFunc
+	states      []string
+}
+
+// wireComponent starts publishing the component's updates and routes its
+// commands. The goroutines it starts run until the component is removed or
+// the server stops.
+func (s *Server) wireComponent(ctx context.Context, cmp component.Settable) *managedComponent {
+	cctx, cancel := context.WithCancel(s.ctx)
+	mc := &managedComponent{cmp: cmp, cancel: cancel}
+
+	if cmpRooter, ok := cmp.(component.TopicRooter); ok {
+		cmpRooter.SetTopicRoot(s.stateRoot)
+	}
+
+	var base component.Base
+	if cmpBase, ok := cmp.(component.BaseComponent); ok {
+		cmpRef := cmpBase.GetBaseReference()
+		if cmpRef.AvailabilityTopic == "" && len(cmpRef.Availability) == 0 {
+			cmpRef.AvailabilityTopic = s.WillTopic()
+		}
+		base = *cmpRef
+	}
+
+	if cmpUpdatable, ok := cmp.(component.Updatable); ok {
+		for _, c := range cmpUpdatable.UpdateChannels() {
+			mc.updates = append(mc.updates, c)
+			mc.states = append(mc.states, c.Topic)
+			mc.wg.Add(1)
+			go func(c component.UpdateChannel) {
+				defer mc.wg.Done()
+				s.runPublisher(cctx, c)
+			}(c)
+		}
+	}
+	if cmpCommandable, ok := cmp.(component.Commandable); ok {
+		for _, c := range cmpCommandable.CommandChannels() {
+			if c.Queue == (component.CommandQueue{}) {
+				c.Queue = base.CommandQueue
+			}
+			unsubscribe, _ := s.subscribe(ctx, c.Topic, &subscription{handler: s.commandQueue(cctx, mc, c.Queue, toChannel(c)), owns: true})
+			mc.unsubscribe = append(mc.unsubscribe, unsubscribe)
+		}
+	}
+	if cmpHandleable, ok := cmp.(component.Handleable); ok {
+		for _, r := range cmpHandleable.CommandRoutes() {
+			r = routeDefaults(r, base)
+			if r.Echo && r.StateTopic != "" {
+				mc.states = append(mc.states, r.StateTopic)
+			}
+			unsubscribe, _ := s.subscribe(ctx, r.Topic, &subscription{handler: s.commandQueue(cctx, mc, r.Queue, s.toHandler(r)), owns: true})
+			mc.unsubscribe = append(mc.unsubscribe, unsubscribe)
+		}
+	}
+
+	return mc
+}
+
+// routeDefaults fills in the settings a command route leaves unset from the
+// component's base.
+func routeDefaults(r component.CommandRoute, base component.Base) component.CommandRoute {
+	if r.Queue == (component.CommandQueue{}) {
+		r.Queue = base.CommandQueue
+	}
+	if r.ErrorTopic == "" {
+		r.ErrorTopic = base.CommandErrorTopic
+	}
+	if !r.Echo && base.EchoCommands {
+		r.Echo = true
+		if r.StateTopic == "" {
+			r.StateTopic = base.StateTopic
+			r.Retain = base.RetainState
+		}
+	}
+	return r
+}
+
+// sameComponent reports whether a and b are the same component instance.
+//
+// Components held by value can't be told apart from a changed copy, so they
+// are never considered the same.
+func sameComponent(a, b component.Settable) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != reflect.Pointer || vb.Kind() != reflect.Pointer {
+		return false
+	}
+	return va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
+// unwireComponent stops the component's goroutines and unsubscribes from its
+// command topics.
+func (s *Server) unwireComponent(ctx context.Context, mc *managedComponent) error {
+	mc.cancel()
+	mc.wg.Wait()
+	s.forgetState(mc.states...)
+
+	var err error
+	for _, unsubscribe := range mc.unsubscribe {
+		if uerr := unsubscribe(ctx); uerr != nil && err == nil {
+			err = uerr
+		}
+	}
+	return err
+}
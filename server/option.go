@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/url"
+	"time"
+)
+
+// Option configures a [Server].
+type Option func(*Server)
+
+// WithDiscoveryPrefix sets the prefix Home Assistant discovers devices
+// under. It defaults to [device.DefaultDiscoveryPrefix].
+func WithDiscoveryPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.discoveryPrefix = prefix
+	}
+}
+
+// WithStateRoot sets the root the default topics of components are moved
+// under. It defaults to the discovery prefix.
+func WithStateRoot(root string) Option {
+	return func(s *Server) {
+		s.stateRoot = root
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for mqtts:// and tls://
+// brokers, such as client certificates for mutual TLS.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Server) {
+		s.conn.tls = cfg
+	}
+}
+
+// WithCredentials sets the username and password to connect with.
+func WithCredentials(username, password string) Option {
+	return func(s *Server) {
+		s.conn.username = username
+		s.conn.password = []byte(password)
+	}
+}
+
+// WithServerURLs adds brokers to connect to when the one passed to [New] is
+// unavailable. They are tried in order.
+func WithServerURLs(urls ...*url.URL) Option {
+	return func(s *Server) {
+		s.conn.urls = append(s.conn.urls, urls...)
+	}
+}
+
+// WithKeepAlive sets the keepalive interval, rounded down to whole seconds.
+// It defaults to 20 seconds.
+func WithKeepAlive(d time.Duration) Option {
+	return func(s *Server) {
+		s.conn.keepAlive = d
+	}
+}
+
+// WithRequestTimeout sets how long to wait for publishes and subscriptions
+// when the context has no deadline. It defaults to 5 seconds.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.reqTimeout = d
+	}
+}
+
+// WithSessionExpiry sets how long the broker keeps the session after the
+// connection is lost, rounded down to whole seconds. It defaults to 0, ending
+// the session when the connection closes.
+func WithSessionExpiry(d time.Duration) Option {
+	return func(s *Server) {
+		s.conn.sessionExpiry = d
+	}
+}
+
+// defaultConnectRetryDelay is how long to wait between connection attempts
+// unless [WithConnectRetryDelay] is used.
+const defaultConnectRetryDelay = 10 * time.Second
+
+// WithConnectRetryDelay sets how long to wait between connection attempts,
+// with either protocol. It defaults to 10 seconds.
+func WithConnectRetryDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.conn.retryDelay = d
+	}
+}
+
+// WithRetainedDiscovery publishes device discovery configs as retained
+// messages, so Home Assistant picks them up without the server having to
+// re-send them.
+func WithRetainedDiscovery(retain bool) Option {
+	return func(s *Server) {
+		s.retainDiscovery = retain
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"lib.hemtjan.st/component"
+)
+
+const (
+	defaultPublishRetries    = 3
+	defaultPublishRetryDelay = time.Second
+	maxPublishRetryDelay     = 30 * time.Second
+)
+
+// WithPublishRetry sets how many times a failed state update is retried, and
+// how long to wait before the first retry. The wait doubles for each retry
+// and is cut short when the connection comes back.
+func WithPublishRetry(retries int, delay time.Duration) Option {
+	return func(s *Server) {
+		s.publishRetries = retries
+		s.publishRetryDelay = delay
+	}
+}
+
+// WithPublishErrorHandler sets a function that is called with the topic and
+// error of every state update that couldn't be published.
+func WithPublishErrorHandler(fn func(topic string, err error)) Option {
+	return func(s *Server) {
+		s.onPublishError = fn
+	}
+}
+
+// PublishErrors returns the number of state updates that couldn't be
+// published.
+func (s *Server) PublishErrors() uint64 {
+	return s.publishErrors.Load()
+}
+
+// runPublisher publishes the updates of a channel until it is closed or ctx
+// is done.
+func (s *Server) runPublisher(ctx context.Context, c component.UpdateChannel) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, open := <-c.Channel:
+			if !open {
+				return
+			}
+			s.publishUpdate(ctx, c, msg)
+		}
+	}
+}
+
+// publishUpdate publishes a state update, retrying if it fails. A publish
+// in flight is let finish when ctx is done, but retries are abandoned when
+// ctx is done or the server stops.
+//
+// Updates are retried a bounded number of times, except on coalescing
+// channels where a newer update replaces the one being retried and retries
+// continue until it is published or the component is torn down.
+func (s *Server) publishUpdate(ctx context.Context, c component.UpdateChannel, msg string) {
+	s.rememberState(c.Topic, []byte(msg), c.Retain)
+
+	delay := s.publishRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := s.publish(context.WithoutCancel(ctx), c.Topic, 1, c.Retain, []byte(msg))
+		if err == nil {
+			return
+		}
+
+		if !c.Coalesce && attempt >= s.publishRetries {
+			s.publishFailed(c.Topic, err)
+			return
+		}
+		if !s.awaitRetry(ctx, delay, err) {
+			select {
+			case <-s.stopped:
+				s.publishFailed(c.Topic, err)
+			default:
+				s.logger.Debug("dropping state of removed component", slog.String("topic", c.Topic))
+			}
+			return
+		}
+		delay = min(delay*2, maxPublishRetryDelay)
+
+		if c.Coalesce {
+			select {
+			case newer, open := <-c.Channel:
+				if open {
+					msg = newer
+					s.rememberState(c.Topic, []byte(msg), c.Retain)
+				}
+			default:
+			}
+		}
+	}
+}
+
+// publishFailed reports a state update that couldn't be published.
+func (s *Server) publishFailed(topic string, err error) {
+	s.logger.Error("unable to publish state", slog.String("topic", topic), slog.String("error", err.Error()))
+	s.publishErrors.Add(1)
+	if s.onPublishError != nil {
+		s.onPublishError(topic, err)
+	}
+}
+
+// awaitRetry waits before retrying a failed publish. If the connection was
+// down, it returns early once the connection is back up. It returns false if
+// the server is stopping or ctx is done.
+func (s *Server) awaitRetry(ctx context.Context, delay time.Duration, err error) bool {
+	wctx, cancel := context.WithTimeout(ctx, delay)
+	defer cancel()
+
+	if errors.Is(err, ErrConnectionDown) {
+		go func() {
+			if s.transport.AwaitConnection(wctx) == nil {
+				cancel()
+			}
+		}()
+	}
+
+	select {
+	case <-s.stopped:
+		return false
+	case <-wctx.Done():
+		return ctx.Err() == nil
+	}
+}
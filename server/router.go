@@ -0,0 +1,54 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"lib.hemtjan.st/internal/topic"
+)
+
+// route passes a received message to the handlers subscribed to a matching
+// topic filter. Messages no handler matches are held by [Server.unrouted].
+func (s *Server) route(m *Message) {
+	s.RLock()
+	var subs []*subscription
+	for filter, fs := range s.handlers {
+		if topic.Match(filter, m.Topic) {
+			subs = append(subs, fs...)
+		}
+	}
+	s.RUnlock()
+
+	if len(subs) == 0 {
+		s.unrouted(m)
+		return
+	}
+	s.dispatch(m, subs)
+}
+
+// dispatch passes a message to the handlers, and acknowledges it once all of
+// them are done with it. Handlers are done when they return, unless they own
+// the message, in which case they are passed a copy to acknowledge when they
+// are done.
+func (s *Server) dispatch(m *Message, subs []*subscription) {
+	var pending atomic.Int32
+	pending.Store(int32(len(subs)))
+	done := func() {
+		if pending.Add(-1) == 0 {
+			m.ack()
+		}
+	}
+
+	handled := *m
+	handled.Ack = nil
+	for _, sub := range subs {
+		if !sub.owns {
+			sub.handler(&handled)
+			done()
+			continue
+		}
+		owned := *m
+		owned.Ack = sync.OnceFunc(done)
+		sub.handler(&owned)
+	}
+}
@@ -3,17 +3,16 @@
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"maps"
 	"net/url"
 	"path"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/eclipse/paho.golang/autopaho"
-	"github.com/eclipse/paho.golang/packets"
-	"github.com/eclipse/paho.golang/paho"
-
 	"lib.hemtjan.st/component"
 	"lib.hemtjan.st/device"
 )
@@ -21,242 +20,477 @@
 type Server struct {
 	Devices []*device.Device
 
-	subscribe []string
+	managed  map[*device.Device]*managedDevice
+	handlers map[string][]*subscription
+	subQoS   byte
+	pending  []*Message
+
+	lastState  map[string]lastState
+	stateDelay time.Duration
+
+	retainDiscovery bool
+	clearOnStop     bool
+	skipValidation  bool
+
+	publishRetries    int
+	publishRetryDelay time.Duration
+	publishErrors     atomic.Uint64
+	onPublishError    func(topic string, err error)
+
+	// ctx is cancelled when the server stops.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// wg tracks background goroutines that aren't tied to a component.
+	wg       sync.WaitGroup
+	stopped  chan struct{}
+	stopOnce sync.Once
 
 	logger     *slog.Logger
 	reqTimeout time.Duration
 
-	pahoConfig autopaho.ClientConfig
-	pahoMgr    *autopaho.ConnectionManager
-	pahoRouter *paho.StandardRouter
+	discoveryPrefix string
+	stateRoot       string
+
+	conn      connConfig
+	protocol  Protocol
+	transport Transport
+	started   bool
 
 	sync.RWMutex
 }
 
-func (s *Server) Subscribe(ctx context.Context, topic string, handler paho.MessageHandler) error {
+// UnsubscribeFunc stops a handler registered with [Server.Subscribe] from
+// receiving messages.
+type UnsubscribeFunc func(ctx context.Context) error
+
+// subscription is a handler registered for a topic filter. It is held by
+// pointer so that it can be told apart from other handlers of the topic.
+type subscription struct {
+	handler MessageHandler
+	// owns is set if the handler acknowledges the messages it is passed
+	// itself.
+	owns bool
+}
+
+// Subscribe calls handler with every message received on topic, which may
+// contain wildcards, until the returned function is called. The server
+// unsubscribes from the topic once its last handler is removed.
+func (s *Server) Subscribe(ctx context.Context, topic string, handler MessageHandler) (UnsubscribeFunc, error) {
+	return s.subscribe(ctx, topic, &subscription{handler: handler})
+}
+
+func (s *Server) subscribe(ctx context.Context, topic string, sub *subscription) (UnsubscribeFunc, error) {
+
 	s.Lock()
-	existing := slices.Contains(s.subscribe, topic)
-	if !existing {
-		s.subscribe = append(s.subscribe, topic)
+	existing := len(s.handlers[topic]) > 0
+	s.handlers[topic] = append(s.handlers[topic], sub)
+	started := s.started
+	s.Unlock()
+
+	unsubscribe := func(ctx context.Context) error {
+		return s.unsubscribe(ctx, topic, sub)
 	}
+
+	if pending := s.takePending(topic); len(pending) > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for _, m := range pending {
+				s.dispatch(m, []*subscription{sub})
+			}
+		}()
+	}
+
+	if !existing && started {
+		rctx, cancel := timeout(ctx, s.reqTimeout)
+		defer cancel()
+		if err := s.transport.Subscribe(rctx, Subscription{Topic: topic, QoS: s.subQoS}); err != nil {
+			return unsubscribe, err
+		}
+	}
+	return unsubscribe, nil
+}
+
+// unsubscribe removes a handler, and unsubscribes from the topic if it was
+// the last one.
+func (s *Server) unsubscribe(ctx context.Context, topic string, sub *subscription) error {
+	s.Lock()
+	subs := s.handlers[topic]
+	i := slices.Index(subs, sub)
+	if i < 0 {
+		s.Unlock()
+		return nil
+	}
+	subs = slices.Delete(slices.Clone(subs), i, i+1)
+	if len(subs) > 0 {
+		s.handlers[topic] = subs
+	} else {
+		delete(s.handlers, topic)
+	}
+	started := s.started
 	s.Unlock()
 
-	s.pahoRouter.RegisterHandler(topic, handler)
-	if !existing && s.pahoMgr != nil {
+	if len(subs) == 0 && started {
 		rctx, cancel := timeout(ctx, s.reqTimeout)
 		defer cancel()
-		_, err := s.pahoMgr.Subscribe(rctx, &paho.Subscribe{Subscriptions: []paho.SubscribeOptions{
-			{Topic: topic},
-		}})
-		return err
+		return s.transport.Unsubscribe(rctx, topic)
 	}
 	return nil
 }
 
 func (s *Server) Publish(ctx context.Context, topic string, qos uint8, msg []byte) error {
+	return s.publish(ctx, topic, qos, false, msg)
+}
+
+// PublishRetained publishes msg as a retained message.
+func (s *Server) PublishRetained(ctx context.Context, topic string, qos uint8, msg []byte) error {
+	return s.publish(ctx, topic, qos, true, msg)
+}
+
+func (s *Server) publish(ctx context.Context, topic string, qos uint8, retain bool, msg []byte) error {
+	return s.publishMessage(ctx, &Message{
+		QoS:     qos,
+		Retain:  retain,
+		Topic:   topic,
+		Payload: msg,
+	})
+}
+
+func (s *Server) publishMessage(ctx context.Context, m *Message) error {
 	rctx, cancel := timeout(ctx, s.reqTimeout)
 	defer cancel()
-	_, err := s.pahoMgr.Publish(rctx,
-		&paho.Publish{
-			QoS:     byte(qos),
-			Topic:   topic,
-			Payload: msg,
-		},
-	)
-
-	return err
+	return s.transport.Publish(rctx, m)
 }
 
+// AddDevice adds the device and publishes its discovery config.
+//
+// Devices that fail validation are refused, see [WithValidation].
 func (s *Server) AddDevice(ctx context.Context, device *device.Device) error {
+	if err := s.validateDevice(device); err != nil {
+		return err
+	}
+
+	md := &managedDevice{components: map[string]*managedComponent{}}
+	for name, cmp := range device.Components {
+		md.components[name] = s.wireComponent(ctx, cmp)
+	}
+
 	s.Lock()
 	s.Devices = append(s.Devices, device)
-	cm := s.pahoMgr
+	s.managed[device] = md
+	started := s.started
+	s.Unlock()
+
+	if started {
+		if err := s.publishDevice(ctx, device, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveDevice removes the device from Home Assistant and stops handling its
+// updates and commands.
+func (s *Server) RemoveDevice(ctx context.Context, dev *device.Device) error {
+	s.Lock()
+	md := s.managed[dev]
+	delete(s.managed, dev)
+	s.Devices = slices.DeleteFunc(s.Devices, func(d *device.Device) bool { return d == dev })
+	started := s.started
 	s.Unlock()
 
-	if device.Components == nil {
+	var errs []error
+	if started {
+		if err := s.publish(ctx, dev.DiscoveryTopicWithPrefix(s.discoveryPrefix), 1, true, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if md != nil {
+		for _, mc := range md.components {
+			errs = append(errs, s.unwireComponent(ctx, mc))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveComponent removes a component from the device and from Home
+// Assistant, and stops handling its updates and commands.
+func (s *Server) RemoveComponent(ctx context.Context, dev *device.Device, name string) error {
+	if _, ok := dev.Components[name]; !ok {
 		return nil
 	}
+	delete(dev.Components, name)
+	return s.UpdateDevice(ctx, dev)
+}
 
-	for _, cmp := range device.Components {
-		if cmpBase, ok := cmp.(component.BaseComponent); ok {
-			cmpRef := cmpBase.GetBaseReference()
-			if cmpRef.AvailabilityTopic == "" && len(cmpRef.Availability) == 0 {
-				cmpRef.AvailabilityTopic = s.WillTopic()
-			}
+// UpdateDevice applies changes to the components of a device that has been
+// added to the server.
+//
+// Components that were added or replaced are wired up, those that were
+// removed are torn down, and the discovery config is published again. A
+// device that hasn't been added yet is added.
+//
+// The server publishes the components it has wired rather than reading the
+// device's components concurrently, so they can be changed with
+// [device.Device.SetComponent] while the server runs. They must not be
+// changed while AddDevice or UpdateDevice is running for the device.
+func (s *Server) UpdateDevice(ctx context.Context, dev *device.Device) error {
+	s.Lock()
+	md, ok := s.managed[dev]
+	started := s.started
+	s.Unlock()
+
+	if !ok {
+		return s.AddDevice(ctx, dev)
+	}
+	if err := s.validateDevice(dev); err != nil {
+		return err
+	}
+
+	// Device discovery requires a removed component to be published with
+	// only its platform before it can be left out of the config.
+	placeholders := map[string]component.Settable{}
+
+	// Replaced and removed components stay in the published config until
+	// the changes are applied, so a concurrent republish doesn't drop them.
+	removed := map[string]*managedComponent{}
+	s.RLock()
+	for name, mc := range md.components {
+		cmp, ok := dev.Components[name]
+		if ok && sameComponent(cmp, mc.cmp) {
+			continue
+		}
+		if !ok {
+			placeholders[name] = component.Removed{Platform: mc.cmp.GetPlatform()}
 		}
+		removed[name] = mc
+	}
+	s.RUnlock()
 
-		if cmpUpdatable, ok := cmp.(component.Updatable); ok {
-			for _, c := range cmpUpdatable.UpdateChannels() {
-				go func(c component.UpdateChannel) {
-					for {
-						msg, open := <-c.Channel
-						if !open {
-							return
-						}
-						_ = s.Publish(ctx, c.Topic, 1, []byte(msg))
-					}
-				}(c)
-			}
+	var err error
+	for _, mc := range removed {
+		if uerr := s.unwireComponent(ctx, mc); uerr != nil && err == nil {
+			err = uerr
 		}
-		if cmpCommandable, ok := cmp.(component.Commandable); ok {
-			for _, c := range cmpCommandable.CommandChannels() {
-				c := c
-				_ = s.Subscribe(ctx, c.Topic, func(publish *paho.Publish) {
-					c.Channel <- string(publish.Payload)
-				})
-			}
+	}
+
+	// Components are wired before they are added to the published config,
+	// as wiring sets their availability and topics.
+	added := map[string]*managedComponent{}
+	for name, cmp := range dev.Components {
+		s.RLock()
+		_, wired := md.components[name]
+		s.RUnlock()
+		if _, replaced := removed[name]; !wired || replaced {
+			added[name] = s.wireComponent(ctx, cmp)
 		}
 	}
 
-	if cm != nil {
-		if err := s.publishDevice(ctx, s.pahoMgr, device); err != nil {
-			return err
+	s.Lock()
+	for name := range removed {
+		delete(md.components, name)
+	}
+	maps.Copy(md.components, added)
+	s.Unlock()
+
+	if started {
+		if perr := s.publishDevice(ctx, dev, placeholders); perr != nil {
+			return perr
 		}
 	}
 
-	return nil
+	return err
 }
 
-func (s *Server) publishDevice(ctx context.Context, cm *autopaho.ConnectionManager, device *device.Device) error {
-	buf, err := json.Marshal(device)
+// publishDevice publishes the discovery config of the device with the
+// components the server has wired, and the placeholders of removed ones.
+func (s *Server) publishDevice(ctx context.Context, dev *device.Device, placeholders map[string]component.Settable) error {
+	s.RLock()
+	published, ok := s.published(dev)
+	if !ok {
+		s.RUnlock()
+		return nil
+	}
+	maps.Copy(published.Components, placeholders)
+	buf, err := json.Marshal(published)
+	s.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	rctx, cancel := timeout(ctx, s.reqTimeout)
-	defer cancel()
-	_, err = cm.Publish(
-		rctx,
-		&paho.Publish{
-			QoS:     byte(1),
-			Topic:   device.DiscoveryTopic(),
-			Payload: buf,
-		},
-	)
+	return s.publish(ctx, dev.DiscoveryTopicWithPrefix(s.discoveryPrefix), 1, s.retainDiscovery, buf)
+}
 
-	return err
+// published returns the device as the server publishes it, with the
+// components it has wired. It must be called with the lock held.
+func (s *Server) published(dev *device.Device) (*device.Device, bool) {
+	md, ok := s.managed[dev]
+	if !ok {
+		return nil, false
+	}
+
+	published := &device.Device{
+		Info:       dev.Info,
+		Origin:     dev.Origin,
+		Components: make(map[string]component.Settable, len(md.components)),
+	}
+	for name, mc := range md.components {
+		published.Components[name] = mc.cmp
+	}
+	return published, true
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	c, err := autopaho.NewConnection(ctx, s.pahoConfig)
-	if err != nil {
-		return err
+// publishDevices publishes the discovery config of every device, and
+// announces the server as online.
+func (s *Server) publishDevices(ctx context.Context) {
+	s.RLock()
+	devs := slices.Clone(s.Devices)
+	s.RUnlock()
+
+	for _, dev := range devs {
+		if err := s.publishDevice(ctx, dev, nil); err != nil {
+			s.logger.Error("unable to publish device", slog.String("error", err.Error()))
+		}
+	}
+
+	if err := s.publish(ctx, s.WillTopic(), 2, false, []byte("online")); err != nil {
+		s.logger.Error("unable to publish status", slog.String("error", err.Error()))
 	}
 
-	if err = c.AwaitConnection(ctx); err != nil {
+	s.wg.Add(1)
+	go s.republishStates(ctx)
+}
+
+// connectionUp restores subscriptions and discovery when the connection to
+// the broker comes up.
+func (s *Server) connectionUp() {
+	s.RLock()
+	var subs []Subscription
+	for topic := range s.handlers {
+		subs = append(subs, Subscription{Topic: topic, QoS: s.subQoS})
+	}
+	s.RUnlock()
+
+	if len(subs) > 0 {
+		rctx, cancel := timeout(s.ctx, s.reqTimeout)
+		defer cancel()
+		if err := s.transport.Subscribe(rctx, subs...); err != nil {
+			s.logger.Error("unable to subscribe", slog.String("error", err.Error()))
+		}
+	}
+
+	s.publishDevices(s.ctx)
+
+	s.logger.Info("MQTT connected")
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	if err := s.transport.Connect(ctx); err != nil {
 		return err
 	}
 
-	s.pahoMgr = c
+	s.Lock()
+	s.started = true
+	s.Unlock()
 
-	return s.Subscribe(ctx, "homeassistant/status", func(publish *paho.Publish) {
-		if string(publish.Payload) == "online" {
-			if len(s.Devices) == 0 {
+	// Discovery is republished outside the status handler, as publishing
+	// waits for acknowledgements the transport may only handle once the
+	// handler has returned. Requests made while republishing are merged.
+	republish := make(chan struct{}, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.ctx.Done():
 				return
+			case <-republish:
+				s.publishDevices(s.ctx)
 			}
-			for _, dev := range s.Devices {
-				if err = s.publishDevice(ctx, s.pahoMgr, dev); err != nil {
-					s.logger.Error("unable to publish device", slog.String("error", err.Error()))
-				}
-			}
+		}
+	}()
 
-			rctx, cancel := timeout(ctx, s.reqTimeout)
-			defer cancel()
-			if _, err := s.pahoMgr.Publish(rctx, &paho.Publish{
-				QoS:     2,
-				Topic:   path.Join("homeassistant", "client", s.pahoConfig.ClientID, "status"),
-				Payload: []byte("online"),
-			}); err != nil {
-				s.logger.Error("unable to publish status", slog.String("error", err.Error()))
-			}
+	_, err := s.Subscribe(ctx, path.Join(s.discoveryPrefix, "status"), func(m *Message) {
+		if string(m.Payload) != "online" {
+			return
+		}
+		select {
+		case republish <- struct{}{}:
+		default:
 		}
 	})
+	return err
+}
+
+// DiscoveryPrefix returns the prefix Home Assistant discovers devices under.
+func (s *Server) DiscoveryPrefix() string {
+	return s.discoveryPrefix
 }
 
 func (s *Server) WillTopic() string {
-	return path.Join("homeassistant", "client", s.pahoConfig.ClientID, "status")
+	return path.Join(s.discoveryPrefix, "client", s.conn.clientID, "status")
 }
 
-func New(ctx context.Context, log *slog.Logger, u string, clientID string) (*Server, error) {
+func New(ctx context.Context, log *slog.Logger, u string, clientID string, opts ...Option) (*Server, error) {
 	srv, err := url.Parse(u)
 	if err != nil {
 		return nil, err
 	}
 
-	r := paho.NewStandardRouterWithDefault(func(p *paho.Publish) {})
-
-	var s *Server
-	s = &Server{
+	s := &Server{
 		reqTimeout: 5 * time.Second,
-		logger:     log,
-		pahoRouter: r,
-		pahoConfig: autopaho.ClientConfig{
-			Errors:                        slog.NewLogLogger(log.Handler(), slog.LevelError),
-			PahoErrors:                    slog.NewLogLogger(log.Handler(), slog.LevelError),
-			ServerUrls:                    []*url.URL{srv},
-			KeepAlive:                     20,
-			CleanStartOnInitialConnection: true,
-			SessionExpiryInterval:         0,
-			DisconnectPacketBuilder: func() *paho.Disconnect {
-				return &paho.Disconnect{ReasonCode: packets.DisconnectDisconnectWithWillMessage}
-			},
-			WillMessage: &paho.WillMessage{
+		managed:    map[*device.Device]*managedDevice{},
+		handlers:   map[string][]*subscription{},
+		lastState:  map[string]lastState{},
+		stopped:    make(chan struct{}),
+
+		publishRetries:    defaultPublishRetries,
+		publishRetryDelay: defaultPublishRetryDelay,
+		discoveryPrefix:   device.DefaultDiscoveryPrefix,
+		logger:            log,
+		conn: connConfig{
+			clientID:   clientID,
+			urls:       []*url.URL{srv},
+			keepAlive:  20 * time.Second,
+			retryDelay: defaultConnectRetryDelay,
+			will: Message{
 				QoS:     2,
-				Topic:   path.Join("homeassistant", "client", clientID, "status"),
 				Payload: []byte("offline"),
 			},
-			OnConnectionUp: func(cm *autopaho.ConnectionManager, connAck *paho.Connack) {
-				s.Lock()
-				devs := s.Devices
-				subscr := s.subscribe
-				s.Unlock()
-
-				if len(subscr) > 0 {
-					var subs []paho.SubscribeOptions
-					for _, topic := range subscr {
-						subs = append(subs, paho.SubscribeOptions{Topic: topic})
-					}
-					rctx, cancel := timeout(ctx, s.reqTimeout)
-					defer cancel()
-					_, err := cm.Subscribe(rctx, &paho.Subscribe{Subscriptions: subs})
-					if err != nil {
-						s.logger.Error("unable to subscribe", slog.String("error", err.Error()))
-					}
-				}
-
-				for _, dev := range devs {
-					if err = s.publishDevice(ctx, cm, dev); err != nil {
-						s.logger.Error("unable to publish device", slog.String("error", err.Error()))
-					}
-				}
-
-				rctx, cancel := timeout(ctx, s.reqTimeout)
-				defer cancel()
-				if _, err := cm.Publish(rctx, &paho.Publish{
-					QoS:     2,
-					Topic:   path.Join("homeassistant", "client", clientID, "status"),
-					Payload: []byte("online"),
-				}); err != nil {
-					s.logger.Error("unable to publish status", slog.String("error", err.Error()))
-				}
-
-				s.logger.Info("MQTT connected")
-			},
-			OnConnectError: func(err error) { s.logger.Error("unable to connect", slog.String("error", err.Error())) },
-			ClientConfig: paho.ClientConfig{
-				ClientID:      clientID,
-				Router:        r,
-				OnClientError: func(err error) { s.logger.Error("client issue", slog.String("error", err.Error())) },
-				OnServerDisconnect: func(d *paho.Disconnect) {
-					if d.Properties != nil {
-						s.logger.Info("server requested disconnect", slog.String("reason", d.Properties.ReasonString))
-					} else {
-						s.logger.Info("server requested disconnect", slog.Int("code", int(d.ReasonCode)))
-					}
-				},
-			},
 		},
 	}
 
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.stateRoot == "" {
+		s.stateRoot = s.discoveryPrefix
+	}
+	s.conn.will.Topic = s.WillTopic()
+	if s.conn.sessionDir != "" {
+		s.subQoS = 2
+	}
+
+	switch {
+	case s.transport != nil:
+		if ws, ok := s.transport.(WillSetter); ok {
+			ws.SetWill(s.conn.will)
+		}
+	case s.protocol == MQTTv311:
+		s.transport, err = newV311Transport(s.conn, log)
+	default:
+		s.transport, err = newPahoTransport(s.conn, log)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.transport.OnConnectionUp(s.connectionUp)
+	s.transport.OnMessage(s.route)
+
 	return s, nil
 }
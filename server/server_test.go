@@ -0,0 +1,384 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"lib.hemtjan.st/bibliotektest"
+	classdevice "lib.hemtjan.st/class/device"
+	"lib.hemtjan.st/component"
+	"lib.hemtjan.st/device"
+	"lib.hemtjan.st/server"
+)
+
+func newDevice(t *testing.T, sensors ...*component.Sensor) *device.Device {
+	t.Helper()
+
+	dev := &device.Device{
+		Info:   device.Info{ID: "test", Name: "Test"},
+		Origin: device.Origin{Name: "bibliotektest"},
+	}
+	for _, s := range sensors {
+		if err := dev.SetComponent(s.ID, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dev
+}
+
+// expectNoState checks that nothing is published on the topic for a while.
+func expectNoState(t *testing.T, h *bibliotektest.Harness, topic string) {
+	t.Helper()
+
+	time.Sleep(50 * time.Millisecond)
+	for _, m := range h.Broker.Messages() {
+		if m.Topic == topic {
+			t.Errorf("got %q on %s", m.Payload, topic)
+		}
+	}
+}
+
+// within fails the test if fn doesn't return within a second.
+func within(t *testing.T, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestAddDevice(t *testing.T) {
+	h := bibliotektest.New(t)
+	temp := component.NewTempSensor("Temperature", "temp")
+	dev := newDevice(t, temp)
+	h.AddDevice(dev)
+	h.AssertDiscovery(dev)
+
+	temp.SetInt(21)
+	h.ExpectState(temp.StateTopic, "21")
+
+	h.RestartHomeAssistant()
+	h.AssertDiscovery(dev)
+	h.ExpectState(temp.StateTopic, "21")
+}
+
+func TestAddDeviceInvalid(t *testing.T) {
+	h := bibliotektest.New(t)
+	dev := newDevice(t, component.NewBinarySensor("Door", "door", classdevice.Temperature))
+	if err := h.Server.AddDevice(context.Background(), dev); !errors.Is(err, component.ErrInvalidDeviceClass) {
+		t.Errorf("got %v, want %v", err, component.ErrInvalidDeviceClass)
+	}
+	if _, ok := h.Broker.Retained(h.DiscoveryTopic(dev)); ok {
+		t.Error("invalid device published")
+	}
+
+	// A component may not share its unique ID with another device's.
+	h.AddDevice(newDevice(t, component.NewTempSensor("Temperature", "temp")))
+	other := newDevice(t, component.NewBatterySensor("Battery", "temp"))
+	other.Info.ID = "other"
+	if err := h.Server.AddDevice(context.Background(), other); !errors.Is(err, device.ErrDuplicateID) {
+		t.Errorf("got %v, want %v", err, device.ErrDuplicateID)
+	}
+}
+
+func TestAddDeviceWithoutValidation(t *testing.T) {
+	h := bibliotektest.New(t, server.WithValidation(false))
+	dev := newDevice(t, component.NewBinarySensor("Door", "door", classdevice.Temperature))
+	h.AddDevice(dev)
+	h.AssertDiscovery(dev)
+}
+
+func TestRemoveDevice(t *testing.T) {
+	h := bibliotektest.New(t)
+	temp := component.NewTempSensor("Temperature", "temp")
+	dev := newDevice(t, temp)
+	h.AddDevice(dev)
+
+	if err := h.Server.RemoveDevice(context.Background(), dev); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.Broker.Retained(h.DiscoveryTopic(dev)); ok {
+		t.Error("discovery config still retained")
+	}
+
+	h.Clear()
+	temp.SetInt(21)
+	expectNoState(t, h, temp.StateTopic)
+}
+
+func TestRemoveDeviceDisconnected(t *testing.T) {
+	h := bibliotektest.New(t)
+	temp := component.NewTempSensor("Temperature", "temp")
+	dev := newDevice(t, temp)
+	h.AddDevice(dev)
+
+	h.Conn.Drop()
+	if err := h.Server.RemoveDevice(context.Background(), dev); !errors.Is(err, server.ErrConnectionDown) {
+		t.Errorf("got %v, want %v", err, server.ErrConnectionDown)
+	}
+	if err := h.Conn.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The device is torn down even though it couldn't be removed from
+	// Home Assistant.
+	h.Clear()
+	temp.SetInt(21)
+	expectNoState(t, h, temp.StateTopic)
+}
+
+func TestRemoveComponent(t *testing.T) {
+	h := bibliotektest.New(t)
+	temp := component.NewTempSensor("Temperature", "temp")
+	battery := component.NewBatterySensor("Battery", "battery")
+	dev := newDevice(t, temp, battery)
+	h.AddDevice(dev)
+	h.Clear()
+
+	if err := h.Server.RemoveComponent(context.Background(), dev, "battery"); err != nil {
+		t.Fatal(err)
+	}
+	cmps, _ := h.Discovery(dev)["cmps"].(map[string]any)
+	if p, _ := cmps["battery"].(map[string]any); len(p) != 1 || p["p"] != "sensor" {
+		t.Errorf("got %v for the removed component, want a placeholder", cmps["battery"])
+	}
+	if _, ok := cmps["temp"]; !ok {
+		t.Error("remaining component missing from discovery config")
+	}
+
+	battery.SetInt(50)
+	temp.SetInt(21)
+	h.ExpectState(temp.StateTopic, "21")
+	expectNoState(t, h, battery.StateTopic)
+}
+
+func TestUpdateDevice(t *testing.T) {
+	h := bibliotektest.New(t)
+	temp := component.NewTempSensor("Temperature", "temp")
+	dev := newDevice(t, temp)
+	h.AddDevice(dev)
+
+	battery := component.NewBatterySensor("Battery", "battery")
+	if err := dev.SetComponent("battery", battery); err != nil {
+		t.Fatal(err)
+	}
+	h.Clear()
+	if err := h.Server.UpdateDevice(context.Background(), dev); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertDiscovery(dev)
+
+	battery.SetInt(50)
+	h.ExpectState(battery.StateTopic, "50")
+}
+
+func TestUpdateDeviceWhileRepublishing(t *testing.T) {
+	h := bibliotektest.New(t)
+	dev := newDevice(t, component.NewTempSensor("Temperature", "temp"))
+	h.AddDevice(dev)
+
+	// Home Assistant coming online makes the server republish discovery
+	// while the device is changed.
+	done := make(chan struct{})
+	republished := make(chan struct{})
+	go func() {
+		defer close(republished)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				h.Broker.Publish(server.Message{Topic: "homeassistant/status", Payload: []byte("online")})
+			}
+		}
+	}()
+
+	for i := range 20 {
+		id := fmt.Sprintf("battery%d", i)
+		if err := dev.SetComponent(id, component.NewBatterySensor("Battery", id)); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Server.UpdateDevice(context.Background(), dev); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := h.Server.RemoveComponent(context.Background(), dev, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	close(done)
+	<-republished
+
+	h.Clear()
+	if err := h.Server.UpdateDevice(context.Background(), dev); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertDiscovery(dev)
+}
+
+func TestSubscribe(t *testing.T) {
+	h := bibliotektest.New(t)
+	ctx := context.Background()
+
+	got := make(chan string, 10)
+	handler := func(name string) server.MessageHandler {
+		return func(m *server.Message) { got <- name + ":" + string(m.Payload) }
+	}
+
+	unsubscribeA, err := h.Server.Subscribe(ctx, "test/topic", handler("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsubscribeB, err := h.Server.Subscribe(ctx, "test/topic", handler("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a handler leaves the others on the topic.
+	if err := unsubscribeA(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.SendCommand("test/topic", "1")
+	select {
+	case m := <-got:
+		if m != "b:1" {
+			t.Errorf("got %s, want b:1", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("remaining handler not called")
+	}
+
+	if err := unsubscribeB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.SendCommand("test/topic", "2")
+	select {
+	case m := <-got:
+		t.Errorf("got %s after unsubscribing", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPublishRetry(t *testing.T) {
+	failed := make(chan string, 1)
+	h := bibliotektest.New(t,
+		server.WithPublishRetry(2, time.Millisecond),
+		server.WithPublishErrorHandler(func(topic string, err error) { failed <- topic }),
+	)
+	temp := component.NewTempSensor("Temperature", "temp")
+	h.AddDevice(newDevice(t, temp))
+
+	h.Conn.Drop()
+	temp.SetInt(21)
+	select {
+	case topic := <-failed:
+		if topic != temp.StateTopic {
+			t.Errorf("got error for %s, want %s", topic, temp.StateTopic)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("publish error not reported")
+	}
+	if n := h.Server.PublishErrors(); n != 1 {
+		t.Errorf("got %d publish errors, want 1", n)
+	}
+
+	if err := h.Conn.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishRetryReconnect(t *testing.T) {
+	h := bibliotektest.New(t, server.WithPublishRetry(1, time.Minute))
+	temp := component.NewTempSensor("Temperature", "temp")
+	h.AddDevice(newDevice(t, temp))
+
+	h.Conn.Drop()
+	temp.SetInt(21)
+	time.Sleep(10 * time.Millisecond)
+	if err := h.Conn.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry doesn't wait out the delay once the connection is back.
+	h.ExpectState(temp.StateTopic, "21")
+}
+
+func TestCoalesceTeardown(t *testing.T) {
+	h := bibliotektest.New(t, server.WithPublishRetry(1, time.Millisecond))
+	temp := component.NewTempSensor("Temperature", "temp")
+	temp.CoalesceState = true
+	dev := newDevice(t, temp)
+	h.AddDevice(dev)
+
+	h.Conn.Drop()
+	for i := range 10 {
+		temp.SetInt(int64(i))
+	}
+
+	// Coalescing updates are retried until the component is torn down,
+	// which must not wait for them to be published.
+	within(t, func() {
+		if err := h.Server.RemoveComponent(context.Background(), dev, "temp"); !errors.Is(err, server.ErrConnectionDown) {
+			t.Errorf("got %v, want %v", err, server.ErrConnectionDown)
+		}
+	})
+	if n := h.Server.PublishErrors(); n != 0 {
+		t.Errorf("got %d publish errors for a removed component, want none", n)
+	}
+
+	if err := h.Conn.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStop(t *testing.T) {
+	h := bibliotektest.New(t, server.WithClearRetainedOnStop(true))
+	temp := component.NewTempSensor("Temperature", "temp")
+	temp.RetainState = true
+	h.AddDevice(newDevice(t, temp))
+
+	for i := range 5 {
+		temp.SetInt(int64(i))
+	}
+	within(t, h.Stop)
+
+	// The last state is published before the server announces that it is
+	// offline, once, and then cleared.
+	var states []string
+	offline, offlines := false, 0
+	for _, m := range h.Broker.Messages() {
+		switch m.Topic {
+		case temp.StateTopic:
+			if offline && len(m.Payload) > 0 {
+				t.Errorf("state %q published after going offline", m.Payload)
+			}
+			states = append(states, string(m.Payload))
+		case h.Server.WillTopic():
+			offline = string(m.Payload) == "offline"
+			if offline {
+				offlines++
+			}
+		}
+	}
+	if offlines != 1 {
+		t.Errorf("server announced as offline %d times, want once", offlines)
+	}
+	if len(states) < 2 || !slices.Equal(states[len(states)-2:], []string{"4", ""}) {
+		t.Errorf("got states %q, want the last one published and then cleared", states)
+	}
+	if _, ok := h.Broker.Retained(temp.StateTopic); ok {
+		t.Error("retained state not cleared")
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"lib.hemtjan.st/internal/topic"
+)
+
+// maxPending bounds how many unrouted messages are kept for redelivery.
+const maxPending = 1000
+
+// defaultSessionExpiry is how long the broker keeps a persistent session if
+// no expiry is given.
+const defaultSessionExpiry = 24 * time.Hour
+
+// WithPersistentSession keeps the MQTT session across restarts, storing its
+// state in dir.
+//
+// Command topics are subscribed with QoS 2, so commands published with QoS 1
+// or 2 while the server is down or disconnected are delivered once it
+// reconnects. If expiry is 0 the broker keeps the session for a day.
+//
+// Commands are only acknowledged once they have been delivered to their
+// component or dropped by its command queue, so commands the server hadn't
+// delivered when it stopped are delivered again once it restarts. Commands
+// received before their component has been added are held unacknowledged
+// until it is. As acknowledgements are sent in the order the commands were
+// received, an undelivered command holds back those received after it, so
+// add devices before calling [Server.Start].
+func WithPersistentSession(dir string, expiry time.Duration) Option {
+	return func(s *Server) {
+		s.conn.sessionDir = dir
+		if expiry == 0 {
+			expiry = defaultSessionExpiry
+		}
+		s.conn.sessionExpiry = expiry
+	}
+}
+
+// unrouted holds messages no handler is registered for, so they can be
+// delivered once one is. Messages are only held with a persistent session,
+// otherwise they are acknowledged and dropped.
+func (s *Server) unrouted(m *Message) {
+	if s.conn.sessionDir == "" {
+		m.ack()
+		return
+	}
+
+	s.Lock()
+	var dropped *Message
+	if len(s.pending) >= maxPending {
+		dropped = s.pending[0]
+		s.pending = slices.Delete(s.pending, 0, 1)
+	}
+	s.pending = append(s.pending, m)
+	s.Unlock()
+
+	if dropped != nil {
+		s.logger.Warn("dropping unrouted message", slog.String("topic", dropped.Topic))
+		dropped.ack()
+	}
+}
+
+// takePending removes and returns the held messages matching the filter.
+func (s *Server) takePending(filter string) []*Message {
+	s.Lock()
+	defer s.Unlock()
+
+	var matched []*Message
+	s.pending = slices.DeleteFunc(s.pending, func(m *Message) bool {
+		if topic.Match(filter, m.Topic) {
+			matched = append(matched, m)
+			return true
+		}
+		return false
+	})
+	return matched
+}
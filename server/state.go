@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// WithStateRepublishDelay sets how long to wait after re-publishing
+// discovery before re-publishing the last known states, giving Home
+// Assistant time to subscribe to the state topics.
+func WithStateRepublishDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.stateDelay = d
+	}
+}
+
+// lastState is the last payload published on a state topic.
+type lastState struct {
+	payload []byte
+	retain  bool
+}
+
+// rememberState records the last payload published on a state topic.
+func (s *Server) rememberState(topic string, payload []byte, retain bool) {
+	s.Lock()
+	s.lastState[topic] = lastState{payload: payload, retain: retain}
+	s.Unlock()
+}
+
+// forgetState drops the last payloads of the topics.
+func (s *Server) forgetState(topics ...string) {
+	s.Lock()
+	for _, topic := range topics {
+		delete(s.lastState, topic)
+	}
+	s.Unlock()
+}
+
+// republishStates publishes the last known payload of every state topic,
+// after the configured delay.
+func (s *Server) republishStates(ctx context.Context) {
+	defer s.wg.Done()
+
+	if s.stateDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopped:
+			return
+		case <-time.After(s.stateDelay):
+		}
+	}
+
+	s.RLock()
+	states := maps.Clone(s.lastState)
+	s.RUnlock()
+
+	for topic, st := range states {
+		if err := s.publish(ctx, topic, 1, st.retain, st.payload); err != nil {
+			s.logger.Error("unable to republish state", slog.String("topic", topic), slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"lib.hemtjan.st/component"
+)
+
+// WithClearRetainedOnStop clears the retained states of all components when
+// the server is stopped, so Home Assistant doesn't show stale values while
+// the server is down.
+func WithClearRetainedOnStop(clear bool) Option {
+	return func(s *Server) {
+		s.clearOnStop = clear
+	}
+}
+
+// Stop shuts the server down.
+//
+// It stops the components, publishes the state updates still queued in
+// their channels, announces the server as offline, optionally clears
+// retained states, disconnects from the broker and waits for all goroutines
+// the server started to exit. Updates being retried are abandoned.
+func (s *Server) Stop(ctx context.Context) error {
+	s.Lock()
+	started := s.started
+	var components []*managedComponent
+	for _, md := range s.managed {
+		for _, mc := range md.components {
+			components = append(components, mc)
+		}
+	}
+	s.Unlock()
+
+	s.stopOnce.Do(func() { close(s.stopped) })
+
+	for _, mc := range components {
+		mc.cancel()
+	}
+	for _, mc := range components {
+		mc.wg.Wait()
+	}
+
+	var errs []error
+	if started {
+		for _, mc := range components {
+			for _, c := range mc.updates {
+				if err := s.flushUpdates(ctx, c); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if err := s.publish(ctx, s.WillTopic(), 2, false, []byte("offline")); err != nil {
+			errs = append(errs, err)
+		}
+
+		if s.clearOnStop {
+			s.RLock()
+			var retained []string
+			for topic, st := range s.lastState {
+				if st.retain {
+					retained = append(retained, topic)
+				}
+			}
+			s.RUnlock()
+
+			for _, topic := range retained {
+				if err := s.publish(ctx, topic, 1, true, nil); err != nil {
+					s.logger.Error("unable to clear retained state", slog.String("topic", topic), slog.String("error", err.Error()))
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	s.cancel()
+
+	if started {
+		if err := s.transport.Disconnect(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	s.wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// flushUpdates publishes the newest update queued in the channel, if any.
+// It must only be called once the channel's publisher has exited.
+func (s *Server) flushUpdates(ctx context.Context, c component.UpdateChannel) error {
+	var (
+		msg     string
+		pending bool
+	)
+	for done := false; !done; {
+		select {
+		case m, open := <-c.Channel:
+			if !open {
+				done = true
+				break
+			}
+			msg, pending = m, true
+		default:
+			done = true
+		}
+	}
+	if !pending {
+		return nil
+	}
+
+	s.rememberState(c.Topic, []byte(msg), c.Retain)
+	return s.publish(ctx, c.Topic, 1, c.Retain, []byte(msg))
+}
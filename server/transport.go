@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"time"
+
+	"lib.hemtjan.st/component"
+)
+
+// ErrConnectionDown is returned when publishing or subscribing while the
+// connection to the broker is down.
+var ErrConnectionDown = errors.New("connection to the MQTT broker is down")
+
+// Protocol is an MQTT protocol version.
+type Protocol int
+
+const (
+	MQTTv5 Protocol = iota
+	MQTTv311
+)
+
+// WithProtocol sets the MQTT protocol version to connect with. It defaults to
+// [MQTTv5].
+//
+// With [MQTTv311] command properties such as the response topic are not
+// available.
+func WithProtocol(p Protocol) Option {
+	return func(s *Server) {
+		s.protocol = p
+	}
+}
+
+// Message is an MQTT message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+
+	// ResponseTopic, CorrelationData and UserProperties are only carried
+	// over MQTT v5.
+	ResponseTopic   string
+	CorrelationData []byte
+	UserProperties  []component.UserProperty
+
+	// Ack acknowledges the message to the broker. Transports that leave
+	// acknowledging to the server set it on the messages they receive, and
+	// the server calls it once the message has been delivered. It is nil
+	// on messages passed to handlers registered with [Server.Subscribe].
+	Ack func()
+}
+
+// ack acknowledges the message if the transport left it to the server.
+func (m *Message) ack() {
+	if m.Ack != nil {
+		m.Ack()
+	}
+}
+
+// MessageHandler is called with messages received on a subscribed topic.
+type MessageHandler func(*Message)
+
+// Subscription is a topic filter to subscribe to.
+type Subscription struct {
+	Topic string
+	QoS   byte
+}
+
+// Transport is a connection to an MQTT broker that keeps itself connected.
+//
+// The server uses an MQTT v5 or v3.1.1 transport depending on
+// [WithProtocol], unless another one is set with [WithTransport].
+type Transport interface {
+	// OnConnectionUp registers a function called every time the connection
+	// comes up, including reconnects.
+	OnConnectionUp(fn func())
+	// OnMessage registers the function all received messages are passed
+	// to.
+	OnMessage(fn MessageHandler)
+
+	// Connect starts connecting and returns once the first connection is
+	// up. ctx only bounds the wait, the connection is kept up until
+	// Disconnect.
+	Connect(ctx context.Context) error
+	// AwaitConnection returns once the connection is up.
+	AwaitConnection(ctx context.Context) error
+	// Disconnect disconnects cleanly and waits for the transport to shut
+	// down.
+	Disconnect(ctx context.Context) error
+
+	// Publish, Subscribe and Unsubscribe return [ErrConnectionDown] if the
+	// connection is down.
+	Publish(ctx context.Context, m *Message) error
+	Subscribe(ctx context.Context, subs ...Subscription) error
+	Unsubscribe(ctx context.Context, topics ...string) error
+}
+
+// WillSetter is implemented by transports that can leave a will message with
+// the broker. The server calls SetWill before Connect with the message that
+// announces it as offline.
+type WillSetter interface {
+	SetWill(m Message)
+}
+
+// WithTransport sets the transport the server connects to the broker with.
+//
+// The transport is responsible for its own connection settings, so the broker
+// URL passed to [New] and the connection options, such as [WithProtocol],
+// [WithCredentials] and [WithPersistentSession], are not used. The will
+// message is passed to transports that implement [WillSetter].
+func WithTransport(t Transport) Option {
+	return func(s *Server) {
+		s.transport = t
+	}
+}
+
+// connConfig is the connection configuration shared by the transports.
+type connConfig struct {
+	clientID   string
+	urls       []*url.URL
+	tls        *tls.Config
+	username   string
+	password   []byte
+	keepAlive  time.Duration
+	retryDelay time.Duration
+	will       Message
+
+	// sessionDir is where a persistent session is stored, or empty for a
+	// clean session.
+	sessionDir    string
+	sessionExpiry time.Duration
+}
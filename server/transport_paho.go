@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.golang/paho/session/state"
+	"github.com/eclipse/paho.golang/paho/store/file"
+
+	"lib.hemtjan.st/component"
+)
+
+var _ Transport = (*pahoTransport)(nil)
+
+// pahoTransport connects with MQTT v5 through autopaho.
+type pahoTransport struct {
+	cfg     autopaho.ClientConfig
+	session *state.State
+
+	onUp      func()
+	onMessage MessageHandler
+
+	mu sync.RWMutex
+	cm *autopaho.ConnectionManager
+}
+
+func newPahoTransport(c connConfig, log *slog.Logger) (*pahoTransport, error) {
+	t := &pahoTransport{}
+
+	// With a persistent session commands are only acknowledged once they
+	// have been delivered, so the broker sends them again if they weren't.
+	manualAck := c.sessionDir != ""
+
+	t.cfg = autopaho.ClientConfig{
+		Errors:                        slog.NewLogLogger(log.Handler(), slog.LevelError),
+		PahoErrors:                    slog.NewLogLogger(log.Handler(), slog.LevelError),
+		ServerUrls:                    c.urls,
+		TlsCfg:                        c.tls,
+		KeepAlive:                     uint16(c.keepAlive / time.Second),
+		ConnectUsername:               c.username,
+		ConnectPassword:               c.password,
+		CleanStartOnInitialConnection: true,
+		SessionExpiryInterval:         uint32(c.sessionExpiry / time.Second),
+		// Stop announces the server as offline itself, so the will is
+		// only sent by the broker if the connection is lost.
+		DisconnectPacketBuilder: func() *paho.Disconnect {
+			return &paho.Disconnect{ReasonCode: packets.DisconnectNormalDisconnection}
+		},
+		WillMessage: &paho.WillMessage{
+			QoS:     c.will.QoS,
+			Retain:  c.will.Retain,
+			Topic:   c.will.Topic,
+			Payload: c.will.Payload,
+		},
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, connAck *paho.Connack) {
+			t.mu.Lock()
+			t.cm = cm
+			onUp := t.onUp
+			t.mu.Unlock()
+
+			if onUp != nil {
+				onUp()
+			}
+		},
+		OnConnectError: func(err error) { log.Error("unable to connect", slog.String("error", err.Error())) },
+		ClientConfig: paho.ClientConfig{
+			ClientID:                   c.clientID,
+			EnableManualAcknowledgment: manualAck,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					t.mu.RLock()
+					onMessage := t.onMessage
+					t.mu.RUnlock()
+
+					if onMessage == nil {
+						return true, nil
+					}
+					m := fromPaho(pr.Packet)
+					if manualAck {
+						m.Ack = func() {
+							if err := pr.Client.Ack(pr.Packet); err != nil {
+								log.Error("unable to acknowledge message", slog.String("topic", m.Topic), slog.String("error", err.Error()))
+							}
+						}
+					}
+					onMessage(m)
+					return true, nil
+				},
+			},
+			OnClientError: func(err error) { log.Error("client issue", slog.String("error", err.Error())) },
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				if d.Properties != nil {
+					log.Info("server requested disconnect", slog.String("reason", d.Properties.ReasonString))
+				} else {
+					log.Info("server requested disconnect", slog.Int("code", int(d.ReasonCode)))
+				}
+			},
+		},
+	}
+
+	if c.retryDelay > 0 {
+		t.cfg.ReconnectBackoff = autopaho.NewConstantBackoff(c.retryDelay)
+	}
+
+	if c.sessionDir != "" {
+		stores := make([]*file.Store, 2)
+		for i, kind := range []string{"client", "server"} {
+			dir := filepath.Join(c.sessionDir, kind)
+			if err := os.MkdirAll(dir, 0o770); err != nil {
+				return nil, fmt.Errorf("unable to create session store: %w", err)
+			}
+			st, err := file.New(dir, kind, ".pkt")
+			if err != nil {
+				return nil, fmt.Errorf("unable to open session store: %w", err)
+			}
+			stores[i] = st
+		}
+
+		t.session = state.New(stores[0], stores[1])
+		t.cfg.Session = t.session
+		t.cfg.CleanStartOnInitialConnection = false
+	}
+
+	return t, nil
+}
+
+func (t *pahoTransport) OnConnectionUp(fn func()) {
+	t.mu.Lock()
+	t.onUp = fn
+	t.mu.Unlock()
+}
+
+func (t *pahoTransport) OnMessage(fn MessageHandler) {
+	t.mu.Lock()
+	t.onMessage = fn
+	t.mu.Unlock()
+}
+
+func (t *pahoTransport) Connect(ctx context.Context) error {
+	// The connection outlives ctx, until Disconnect.
+	cm, err := autopaho.NewConnection(context.WithoutCancel(ctx), t.cfg)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.cm = cm
+	t.mu.Unlock()
+
+	if err := cm.AwaitConnection(ctx); err != nil {
+		t.mu.Lock()
+		t.cm = nil
+		t.mu.Unlock()
+		return errors.Join(err, cm.Disconnect(context.WithoutCancel(ctx)))
+	}
+	return nil
+}
+
+func (t *pahoTransport) manager() (*autopaho.ConnectionManager, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.cm == nil {
+		return nil, ErrConnectionDown
+	}
+	return t.cm, nil
+}
+
+func (t *pahoTransport) AwaitConnection(ctx context.Context) error {
+	cm, err := t.manager()
+	if err != nil {
+		return err
+	}
+	return cm.AwaitConnection(ctx)
+}
+
+func (t *pahoTransport) Disconnect(ctx context.Context) error {
+	cm, err := t.manager()
+	if err != nil {
+		return nil
+	}
+
+	var errs []error
+	if err := cm.Disconnect(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	select {
+	case <-cm.Done():
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+	}
+
+	if t.session != nil {
+		if err := t.session.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *pahoTransport) Publish(ctx context.Context, m *Message) error {
+	cm, err := t.manager()
+	if err != nil {
+		return err
+	}
+	_, err = cm.Publish(ctx, toPaho(m))
+	return pahoError(err)
+}
+
+func (t *pahoTransport) Subscribe(ctx context.Context, subs ...Subscription) error {
+	cm, err := t.manager()
+	if err != nil {
+		return err
+	}
+
+	sub := &paho.Subscribe{}
+	for _, s := range subs {
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: s.Topic, QoS: s.QoS})
+	}
+	_, err = cm.Subscribe(ctx, sub)
+	return pahoError(err)
+}
+
+func (t *pahoTransport) Unsubscribe(ctx context.Context, topics ...string) error {
+	cm, err := t.manager()
+	if err != nil {
+		return err
+	}
+	_, err = cm.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+	return pahoError(err)
+}
+
+func pahoError(err error) error {
+	if errors.Is(err, autopaho.ConnectionDownError) {
+		return ErrConnectionDown
+	}
+	return err
+}
+
+func toPaho(m *Message) *paho.Publish {
+	p := &paho.Publish{
+		QoS:     m.QoS,
+		Retain:  m.Retain,
+		Topic:   m.Topic,
+		Payload: m.Payload,
+	}
+
+	if m.ResponseTopic != "" || m.CorrelationData != nil || len(m.UserProperties) > 0 {
+		p.Properties = &paho.PublishProperties{
+			ResponseTopic:   m.ResponseTopic,
+			CorrelationData: m.CorrelationData,
+		}
+		for _, u := range m.UserProperties {
+			p.Properties.User = append(p.Properties.User, paho.UserProperty{Key: u.Key, Value: u.Value})
+		}
+	}
+
+	return p
+}
+
+func fromPaho(p *paho.Publish) *Message {
+	m := &Message{
+		Topic:   p.Topic,
+		Payload: p.Payload,
+		QoS:     p.QoS,
+		Retain:  p.Retain,
+	}
+
+	if props := p.Properties; props != nil {
+		m.ResponseTopic = props.ResponseTopic
+		m.CorrelationData = props.CorrelationData
+		for _, u := range props.User {
+			m.UserProperties = append(m.UserProperties, component.UserProperty{Key: u.Key, Value: u.Value})
+		}
+	}
+
+	return m
+}
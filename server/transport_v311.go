@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var _ Transport = (*v311Transport)(nil)
+
+// v311Transport connects with MQTT v3.1.1 through the paho.mqtt.golang
+// client.
+type v311Transport struct {
+	client mqtt.Client
+
+	mu        sync.Mutex
+	up        chan struct{}
+	onUp      func()
+	onMessage MessageHandler
+}
+
+func newV311Transport(c connConfig, log *slog.Logger) (*v311Transport, error) {
+	t := &v311Transport{
+		up: make(chan struct{}),
+	}
+
+	opts := mqtt.NewClientOptions().
+		SetProtocolVersion(4).
+		SetClientID(c.clientID).
+		SetTLSConfig(c.tls).
+		SetUsername(c.username).
+		SetPassword(string(c.password)).
+		SetKeepAlive(c.keepAlive).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Commands must reach their queues in the order they were sent.
+		SetOrderMatters(true).
+		SetBinaryWill(c.will.Topic, c.will.Payload, c.will.QoS, c.will.Retain).
+		SetDefaultPublishHandler(func(_ mqtt.Client, msg mqtt.Message) {
+			t.mu.Lock()
+			onMessage := t.onMessage
+			t.mu.Unlock()
+
+			if onMessage == nil {
+				msg.Ack()
+				return
+			}
+			m := &Message{
+				Topic:   msg.Topic(),
+				Payload: msg.Payload(),
+				QoS:     msg.Qos(),
+				Retain:  msg.Retained(),
+			}
+			if c.sessionDir != "" {
+				m.Ack = msg.Ack
+			}
+			onMessage(m)
+		}).
+		SetOnConnectHandler(func(mqtt.Client) {
+			t.mu.Lock()
+			select {
+			case <-t.up:
+			default:
+				close(t.up)
+			}
+			onUp := t.onUp
+			t.mu.Unlock()
+
+			if onUp != nil {
+				onUp()
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			t.mu.Lock()
+			t.up = make(chan struct{})
+			t.mu.Unlock()
+
+			log.Error("connection lost", slog.String("error", err.Error()))
+		})
+
+	for _, u := range c.urls {
+		opts.AddBroker(u.String())
+	}
+
+	if c.retryDelay > 0 {
+		opts.SetConnectRetryInterval(c.retryDelay)
+		opts.SetMaxReconnectInterval(c.retryDelay)
+	}
+
+	if c.sessionDir != "" {
+		if err := os.MkdirAll(c.sessionDir, 0o770); err != nil {
+			return nil, err
+		}
+		// Commands are only acknowledged once they have been delivered,
+		// so the broker sends them again if they weren't.
+		opts.SetCleanSession(false).
+			SetResumeSubs(true).
+			SetAutoAckDisabled(true).
+			SetStore(mqtt.NewFileStore(c.sessionDir))
+	}
+
+	t.client = mqtt.NewClient(opts)
+	return t, nil
+}
+
+func (t *v311Transport) OnConnectionUp(fn func()) {
+	t.mu.Lock()
+	t.onUp = fn
+	t.mu.Unlock()
+}
+
+func (t *v311Transport) OnMessage(fn MessageHandler) {
+	t.mu.Lock()
+	t.onMessage = fn
+	t.mu.Unlock()
+}
+
+func (t *v311Transport) Connect(ctx context.Context) error {
+	tok := t.client.Connect()
+	select {
+	case <-tok.Done():
+		if err := tok.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		t.client.Disconnect(0)
+		return ctx.Err()
+	}
+
+	if err := t.AwaitConnection(ctx); err != nil {
+		t.client.Disconnect(0)
+		return err
+	}
+	return nil
+}
+
+func (t *v311Transport) AwaitConnection(ctx context.Context) error {
+	t.mu.Lock()
+	up := t.up
+	t.mu.Unlock()
+
+	select {
+	case <-up:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *v311Transport) Disconnect(ctx context.Context) error {
+	quiesce := uint(250)
+	if deadline, ok := ctx.Deadline(); ok {
+		quiesce = uint(min(time.Until(deadline), 250*time.Millisecond).Milliseconds())
+	}
+	t.client.Disconnect(quiesce)
+	return nil
+}
+
+func (t *v311Transport) Publish(ctx context.Context, m *Message) error {
+	if !t.client.IsConnectionOpen() {
+		return ErrConnectionDown
+	}
+	return waitToken(ctx, t.client.Publish(m.Topic, m.QoS, m.Retain, m.Payload))
+}
+
+func (t *v311Transport) Subscribe(ctx context.Context, subs ...Subscription) error {
+	if !t.client.IsConnectionOpen() {
+		return ErrConnectionDown
+	}
+
+	filters := map[string]byte{}
+	for _, s := range subs {
+		filters[s.Topic] = s.QoS
+	}
+	return waitToken(ctx, t.client.SubscribeMultiple(filters, nil))
+}
+
+func (t *v311Transport) Unsubscribe(ctx context.Context, topics ...string) error {
+	if !t.client.IsConnectionOpen() {
+		return ErrConnectionDown
+	}
+	return waitToken(ctx, t.client.Unsubscribe(topics...))
+}
+
+func waitToken(ctx context.Context, tok mqtt.Token) error {
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
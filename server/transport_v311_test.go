@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestV311Transport(t *testing.T, sessionDir string) *v311Transport {
+	t.Helper()
+
+	u, err := url.Parse("tcp://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newV311Transport(connConfig{
+		clientID:   "test",
+		urls:       []*url.URL{u},
+		keepAlive:  time.Minute,
+		will:       Message{Topic: "test/status", Payload: []byte("offline"), QoS: 2},
+		sessionDir: sessionDir,
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestV311TransportOptions(t *testing.T) {
+	opts := newTestV311Transport(t, "").client.OptionsReader()
+	if !opts.Order() {
+		t.Error("messages aren't handled in order")
+	}
+	if v := opts.ProtocolVersion(); v != 4 {
+		t.Errorf("got protocol version %d, want 4", v)
+	}
+	if !opts.WillEnabled() || opts.WillTopic() != "test/status" || string(opts.WillPayload()) != "offline" || opts.WillQos() != 2 {
+		t.Errorf("got will %q on %s with QoS %d, want offline on test/status with QoS 2", opts.WillPayload(), opts.WillTopic(), opts.WillQos())
+	}
+	if !opts.CleanSession() {
+		t.Error("session kept without a session dir")
+	}
+
+	opts = newTestV311Transport(t, t.TempDir()).client.OptionsReader()
+	if opts.CleanSession() || !opts.ResumeSubs() {
+		t.Error("session not kept with a session dir")
+	}
+}
+
+func TestV311TransportDisconnected(t *testing.T) {
+	tr := newTestV311Transport(t, "")
+	ctx := context.Background()
+
+	if err := tr.Publish(ctx, &Message{Topic: "test"}); !errors.Is(err, ErrConnectionDown) {
+		t.Errorf("publish: got %v, want %v", err, ErrConnectionDown)
+	}
+	if err := tr.Subscribe(ctx, Subscription{Topic: "test"}); !errors.Is(err, ErrConnectionDown) {
+		t.Errorf("subscribe: got %v, want %v", err, ErrConnectionDown)
+	}
+	if err := tr.Unsubscribe(ctx, "test"); !errors.Is(err, ErrConnectionDown) {
+		t.Errorf("unsubscribe: got %v, want %v", err, ErrConnectionDown)
+	}
+
+	actx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := tr.AwaitConnection(actx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("await connection: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
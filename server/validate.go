@@ -0,0 +1,36 @@
+package server
+
+import (
+	"lib.hemtjan.st/device"
+)
+
+// WithValidation sets whether devices are validated before they are added or
+// updated. It defaults to true, refusing devices Home Assistant would reject.
+func WithValidation(validate bool) Option {
+	return func(s *Server) {
+		s.skipValidation = !validate
+	}
+}
+
+// validateDevice checks the device, and that its components don't share
+// unique IDs with those the server has wired for the other devices.
+func (s *Server) validateDevice(dev *device.Device) error {
+	if s.skipValidation {
+		return nil
+	}
+
+	if err := dev.Validate(); err != nil {
+		return err
+	}
+
+	s.RLock()
+	var devs []*device.Device
+	for _, d := range s.Devices {
+		if published, ok := s.published(d); ok && d != dev {
+			devs = append(devs, published)
+		}
+	}
+	s.RUnlock()
+
+	return device.CheckUniqueIDs(append(devs, dev)...)
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"slices"
+
+	"lib.hemtjan.st/class/device"
+)
+
+// None is the absence of a unit, for device classes that are unitless.
+const None Measurement = ""
+
+var (
+	apparentPower = []Measurement{VoltAmpere, MilliVoltAmpere, KiloVoltAmpere}
+	area          = []Measurement{
+		SquareMillimeter, SquareCentimeter, SquareMeter, SquareKilometer,
+		SquareInch, SquareFoot, SquareYard, SquareMile, Acre, Hectare,
+	}
+	dataRate = []Measurement{
+		BitPerSecond, KilobitPerSecond, MegabitPerSecond, GigabitPerSecond,
+		BytePerSecond, KilobytePerSecond, MegabytePerSecond, GigabytePerSecond,
+		KibibytePerSecond, MebibytePerSecond, GibibytePerSecond,
+	}
+	dataSize = []Measurement{
+		Bit, Kilobit, Megabit, Gigabit, Byte, Kilobyte, Megabyte, Gigabyte,
+		Terabyte, Petabyte, Exabyte, Zettabyte, Yottabyte, Kibibyte, Mebibyte,
+		Gibibyte, Tebibyte, Pebibyte, Exbibyte, Zebibyte, Yobibyte,
+	}
+	distance = []Measurement{
+		Millimeter, Centimeter, Meter, Kilometer, Inch, Foot, Yard, Mile,
+		NauticalMile,
+	}
+	duration = []Measurement{MicroSecond, MilliSecond, Second, Minute, Hour, Day}
+	energy   = []Measurement{
+		Joule, KiloJoule, MegaJoule, GigaJoule, MilliWattHour, WattHour,
+		KiloWattHour, MegaWattHour, GigaWattHour, TeraWattHour, Calorie,
+		KiloCalorie, MegaCalorie, GigaCalorie,
+	}
+	power = []Measurement{
+		MilliWatt, Watt, KiloWatt, MegaWatt, GigaWatt, TeraWatt, BTUPerHour,
+	}
+	pressure = []Measurement{
+		Pascal, HectoPascal, KiloPascal, Bar, CentiBar, MilliBar,
+		MillimeterOfMercury, InchOfMercury, InchOfWater, PSI,
+	}
+	speed = []Measurement{
+		Beaufort, FootPerSecond, InchPerSecond, MeterPerSecond,
+		KilometerPerHour, Knot, MilePerHour, MillimeterPerSecond,
+		InchPerDay, InchPerHour, MillimeterPerDay, MillimeterPerHour,
+	}
+	volume = []Measurement{
+		Milliliter, Liter, CubicMeter, CubicFoot, CentumCubicFoot,
+		MilleCubicFoot, Gallon, FluidOunce,
+	}
+	volumeFlowRate = []Measurement{
+		CubicMeterPerHour, CubicMeterPerSecond, CubicFootPerMinute,
+		LiterPerHour, LiterPerMinute, LiterPerSecond, GallonPerMinute,
+		GallonPerDay, MilliliterPerSecond,
+	}
+	weight = []Measurement{
+		Microgram, Milligram, Gram, Kilogram, Ounce, Pound, Stone,
+	}
+)
+
+var units = map[device.Class][]Measurement{
+	device.AbsoluteHumidity:          {GramsPerCubicMeter, MilligramsPerCubicMeter},
+	device.ApparentPower:             apparentPower,
+	device.AQI:                       {None},
+	device.Area:                      area,
+	device.AtmosphericPressure:       pressure,
+	device.Battery:                   {Percent},
+	device.BloodGlucoseConcentration: {MilligramsPerDeciliter, MillimolePerLiter},
+	device.CarbonDioxide:             {PartsPerMillion},
+	device.CarbonMonoxide: {
+		PartsPerMillion, PartsPerBillion, MilligramsPerCubicMeter,
+		MicrogramsPerCubicMeter,
+	},
+	device.Conductivity: {
+		SiemensPerCentimeter, MilliSiemensPerCentimeter,
+		MicroSiemensPerCentimeter,
+	},
+	device.Current:  {Ampere, MilliAmpere},
+	device.DataRate: dataRate,
+	device.DataSize: dataSize,
+	device.Date:     {None},
+	device.Distance: distance,
+	device.Duration: duration,
+	device.Energy:   energy,
+	device.EnergyDistance: {
+		KiloWattHourPer100Kilometer, WattHourPerKilometer,
+		MilePerKiloWattHour, KilometerPerKiloWattHour,
+	},
+	device.EnergyStorage:          energy,
+	device.Enum:                   {None},
+	device.Frequency:              {Hertz, KiloHertz, MegaHertz, GigaHertz},
+	device.Gas:                    {CubicMeter, CubicFoot, CentumCubicFoot, MilleCubicFoot, Liter},
+	device.Illuminance:            {Lux},
+	device.Irradiance:             {WattPerSquareMeter, BTUPerHourSquareFoot},
+	device.Moisture:               {Percent},
+	device.NitrogenDioxide:        {MicrogramsPerCubicMeter, PartsPerBillion, PartsPerMillion},
+	device.NitrogenMonoxide:       {MicrogramsPerCubicMeter},
+	device.NitrousOxide:           {MicrogramsPerCubicMeter},
+	device.Ozone:                  {MicrogramsPerCubicMeter},
+	device.PH:                     {None},
+	device.PM1:                    {MicrogramsPerCubicMeter},
+	device.PM25:                   {MicrogramsPerCubicMeter},
+	device.PM4:                    {MicrogramsPerCubicMeter},
+	device.PM10:                   {MicrogramsPerCubicMeter},
+	device.Power:                  power,
+	device.PowerFactor:            {Percent, None},
+	device.Precipitation:          {Centimeter, Inch, Millimeter},
+	device.PrecipitationIntensity: {InchPerDay, InchPerHour, MillimeterPerDay, MillimeterPerHour},
+	device.Pressure:               pressure,
+	device.ReactiveEnergy:         {VoltAmpereReactiveHour, KiloVoltAmpereReactiveHour},
+	device.ReactivePower:          {VoltAmpereReactive, MilliVoltAmpereReactive, KiloVoltAmpereReactive},
+	device.RelativeHumidity:       {Percent},
+	device.SignalStrength:         {Decibel, DecibelMilliwatt},
+	device.SoundPressure:          {Decibel, DecibelAWeighted},
+	device.Speed:                  speed,
+	device.SulphurDioxide:         {MicrogramsPerCubicMeter},
+	device.Temperature:            {Celsius, Fahrenheit, Kelvin},
+	device.Timestamp:              {None},
+	device.VolatileOrganicCompounds: {
+		MicrogramsPerCubicMeter, MilligramsPerCubicMeter,
+	},
+	device.VolatileOrganicCompoundsParts: {PartsPerMillion, PartsPerBillion},
+	device.Voltage:                       {MicroVolt, MilliVolt, Volt, KiloVolt, MegaVolt},
+	device.Volume:                        volume,
+	device.VolumeFlowRate:                volumeFlowRate,
+	device.VolumeStorage:                 volume,
+	device.Water:                         {Liter, Gallon, CubicMeter, CubicFoot, CentumCubicFoot, MilleCubicFoot},
+	device.Weight:                        weight,
+	device.WindDirection:                 {Degree},
+	device.WindSpeed:                     speed,
+}
+
+// For returns the units accepted by a sensor with the device class. A [None]
+// entry means the sensor may be unitless.
+//
+// The second return value is false if the class doesn't restrict its units,
+// such as [device.Monetary] which takes any ISO 4217 currency code.
+func For(c device.Class) ([]Measurement, bool) {
+	u, ok := units[c]
+	return slices.Clone(u), ok
+}
+
+// ValidFor reports whether a sensor with the device class accepts the unit.
+func (m Measurement) ValidFor(c device.Class) bool {
+	valid, ok := units[c]
+	if !ok {
+		return true
+	}
+	return slices.Contains(valid, m)
+}
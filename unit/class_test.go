@@ -0,0 +1,42 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"lib.hemtjan.st/class/device"
+)
+
+// Home Assistant spells the micro prefix with the Greek letter mu (U+03BC),
+// not the micro sign (U+00B5), and rejects units spelled the other way.
+func TestMicroPrefix(t *testing.T) {
+	const mu, microSign = "μ", "µ"
+
+	for c, ms := range units {
+		for _, m := range ms {
+			if strings.Contains(string(m), microSign) {
+				t.Errorf("%s: %q uses the micro sign", c, m)
+			}
+		}
+	}
+
+	for m, want := range map[Measurement]string{
+		MicroSiemensPerCentimeter: mu + "S/cm",
+		MicrogramsPerCubicMeter:   mu + "g/m³",
+		MicrogramsPerCubicFoot:    mu + "g/ft³",
+		MicroVolt:                 mu + "V",
+		MicroSecond:               mu + "s",
+		Microgram:                 mu + "g",
+	} {
+		if string(m) != want {
+			t.Errorf("got %q, want %q", m, want)
+		}
+	}
+
+	if !Measurement(mu + "g/m³").ValidFor(device.PM25) {
+		t.Errorf("%q not accepted for %s", mu+"g/m³", device.PM25)
+	}
+	if Measurement(microSign + "g/m³").ValidFor(device.PM25) {
+		t.Errorf("%q accepted for %s", microSign+"g/m³", device.PM25)
+	}
+}
@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownUnit is returned when converting from or to a unit with no
+	// known conversion.
+	ErrUnknownUnit = errors.New("no conversion for unit")
+	// ErrIncompatibleUnits is returned when converting between units that
+	// measure different quantities.
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+type family int
+
+const (
+	familyTemperature family = iota + 1
+	familyEnergy
+	familyPower
+	familyPressure
+	familyLength
+	familyVolume
+	familySpeed
+	familyDuration
+)
+
+type factor struct {
+	family family
+	// scale is the value of one unit in the family's base unit.
+	scale float64
+}
+
+// factors is keyed on the unit constants, which are unique even where
+// symbols aren't.
+var factors = map[Measurement]factor{
+	Joule:         {familyEnergy, 1},
+	KiloJoule:     {familyEnergy, 1e3},
+	MegaJoule:     {familyEnergy, 1e6},
+	GigaJoule:     {familyEnergy, 1e9},
+	MilliWattHour: {familyEnergy, 3.6},
+	WattHour:      {familyEnergy, 3.6e3},
+	KiloWattHour:  {familyEnergy, 3.6e6},
+	MegaWattHour:  {familyEnergy, 3.6e9},
+	GigaWattHour:  {familyEnergy, 3.6e12},
+	TeraWattHour:  {familyEnergy, 3.6e15},
+	Calorie:       {familyEnergy, 4.184},
+	KiloCalorie:   {familyEnergy, 4.184e3},
+	MegaCalorie:   {familyEnergy, 4.184e6},
+	GigaCalorie:   {familyEnergy, 4.184e9},
+
+	MilliWatt:  {familyPower, 1e-3},
+	Watt:       {familyPower, 1},
+	KiloWatt:   {familyPower, 1e3},
+	MegaWatt:   {familyPower, 1e6},
+	GigaWatt:   {familyPower, 1e9},
+	TeraWatt:   {familyPower, 1e12},
+	BTUPerHour: {familyPower, 0.29307107017222},
+
+	Pascal:              {familyPressure, 1},
+	HectoPascal:         {familyPressure, 1e2},
+	KiloPascal:          {familyPressure, 1e3},
+	Bar:                 {familyPressure, 1e5},
+	CentiBar:            {familyPressure, 1e3},
+	MilliBar:            {familyPressure, 1e2},
+	MillimeterOfMercury: {familyPressure, 133.322387415},
+	InchOfMercury:       {familyPressure, 3386.389},
+	InchOfWater:         {familyPressure, 249.0889},
+	PSI:                 {familyPressure, 6894.757293168},
+
+	Millimeter:   {familyLength, 1e-3},
+	Centimeter:   {familyLength, 1e-2},
+	Meter:        {familyLength, 1},
+	Kilometer:    {familyLength, 1e3},
+	Inch:         {familyLength, 0.0254},
+	Foot:         {familyLength, 0.3048},
+	Yard:         {familyLength, 0.9144},
+	Mile:         {familyLength, 1609.344},
+	NauticalMile: {familyLength, 1852},
+
+	Milliliter:      {familyVolume, 1e-6},
+	Liter:           {familyVolume, 1e-3},
+	CubicMeter:      {familyVolume, 1},
+	CubicFoot:       {familyVolume, 0.028316846592},
+	CentumCubicFoot: {familyVolume, 2.8316846592},
+	MilleCubicFoot:  {familyVolume, 28.316846592},
+	Gallon:          {familyVolume, 0.003785411784},
+	FluidOunce:      {familyVolume, 0.0000295735295625},
+
+	FootPerSecond:       {familySpeed, 0.3048},
+	InchPerSecond:       {familySpeed, 0.0254},
+	MeterPerSecond:      {familySpeed, 1},
+	KilometerPerHour:    {familySpeed, 1 / 3.6},
+	Knot:                {familySpeed, 1852.0 / 3600},
+	MilePerHour:         {familySpeed, 0.44704},
+	MillimeterPerSecond: {familySpeed, 1e-3},
+	InchPerDay:          {familySpeed, 0.0254 / 86400},
+	InchPerHour:         {familySpeed, 0.0254 / 3600},
+	MillimeterPerDay:    {familySpeed, 1e-3 / 86400},
+	MillimeterPerHour:   {familySpeed, 1e-3 / 3600},
+
+	MicroSecond: {familyDuration, 1e-6},
+	MilliSecond: {familyDuration, 1e-3},
+	Second:      {familyDuration, 1},
+	Minute:      {familyDuration, 60},
+	Hour:        {familyDuration, 3600},
+	Day:         {familyDuration, 86400},
+	Week:        {familyDuration, 604800},
+
+	Celsius:    {familyTemperature, 1},
+	Fahrenheit: {familyTemperature, 1},
+	Kelvin:     {familyTemperature, 1},
+}
+
+// Convert converts value from one unit to another.
+//
+// Temperature, energy, power, pressure, length, volume, speed and duration
+// units are supported, except months and years which have no fixed length.
+// Converting between units of different quantities returns
+// [ErrIncompatibleUnits].
+func Convert(value float64, from, to Measurement) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+
+	f, ok := factors[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, from)
+	}
+	t, ok := factors[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, to)
+	}
+	if f.family != t.family {
+		return 0, fmt.Errorf("%w: %q and %q", ErrIncompatibleUnits, from, to)
+	}
+
+	if f.family == familyTemperature {
+		return fromKelvin(toKelvin(value, from), to), nil
+	}
+
+	return value * f.scale / t.scale, nil
+}
+
+func toKelvin(v float64, from Measurement) float64 {
+	switch from {
+	case Celsius:
+		return v + 273.15
+	case Fahrenheit:
+		return (v-32)*5/9 + 273.15
+	}
+	return v
+}
+
+func fromKelvin(v float64, to Measurement) float64 {
+	switch to {
+	case Celsius:
+		return v - 273.15
+	case Fahrenheit:
+		return (v-273.15)*9/5 + 32
+	}
+	return v
+}
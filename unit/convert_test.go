@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to Measurement
+		want     float64
+		err      error
+	}{
+		{1, Meter, Kilometer, 0.001, nil},
+		{1, Mile, Meter, 1609.344, nil},
+		{2, KiloWattHour, WattHour, 2000, nil},
+		{1, Bar, HectoPascal, 1000, nil},
+		{36, KilometerPerHour, MeterPerSecond, 10, nil},
+		{90, Minute, Hour, 1.5, nil},
+		{1, Week, Day, 7, nil},
+		{20, Celsius, Fahrenheit, 68, nil},
+		{0, Celsius, Kelvin, 273.15, nil},
+		{5, Watt, Watt, 5, nil},
+		{1, Month, Kilometer, 0, ErrUnknownUnit},
+		{1, Month, Day, 0, ErrUnknownUnit},
+		{1, Meter, Second, 0, ErrIncompatibleUnits},
+		{1, Celsius, Watt, 0, ErrIncompatibleUnits},
+		{1, Liter, Meter, 0, ErrIncompatibleUnits},
+		{1, Percent, Meter, 0, ErrUnknownUnit},
+	}
+
+	for _, tt := range tests {
+		got, err := Convert(tt.value, tt.from, tt.to)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Convert(%v, %q, %q) error = %v, want %v", tt.value, tt.from, tt.to, err, tt.err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMonthSymbol(t *testing.T) {
+	buf, err := json.Marshal(struct{ Unit Measurement }{Month})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), `{"Unit":"m"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,13 +1,33 @@
 package unit
 
+// Measurement is a unit of measurement.
+//
+// Every unit has its own value, which is also its symbol except for [Month],
+// see [Measurement.MarshalText].
 type Measurement string
 
+// MarshalText returns the symbol of the unit.
+//
+// [Month] shares the symbol "m" with [Meter], so a decoded "m" is always
+// [Meter].
+func (m Measurement) MarshalText() ([]byte, error) {
+	if m == Month {
+		return []byte("m"), nil
+	}
+	return []byte(m), nil
+}
+
 const (
 	Ampere      Measurement = "A"
 	MilliAmpere Measurement = "mA"
 
-	Celsius Measurement = "°C"
-	Kelvin  Measurement = "K"
+	VoltAmpere      Measurement = "VA"
+	MilliVoltAmpere Measurement = "mVA"
+	KiloVoltAmpere  Measurement = "kVA"
+
+	Celsius    Measurement = "°C"
+	Fahrenheit Measurement = "°F"
+	Kelvin     Measurement = "K"
 
 	Calorie     Measurement = "cal"
 	KiloCalorie Measurement = "kcal"
@@ -17,25 +37,185 @@
 	Joule     Measurement = "J"
 	KiloJoule Measurement = "kJ"
 	MegaJoule Measurement = "MJ"
+	GigaJoule Measurement = "GJ"
 
 	Lux Measurement = "lx"
 
-	MicrogramsPerCubicMeter = "µg/m³"
+	WattPerSquareMeter        Measurement = "W/m²"
+	BTUPerHourSquareFoot      Measurement = "BTU/(h⋅ft²)"
+	UVIndex                   Measurement = "UV index"
+	Degree                    Measurement = "°"
+	MicroSiemensPerCentimeter Measurement = "μS/cm"
+	MilliSiemensPerCentimeter Measurement = "mS/cm"
+	SiemensPerCentimeter      Measurement = "S/cm"
+	MilligramsPerDeciliter    Measurement = "mg/dL"
+	MillimolePerLiter         Measurement = "mmol/L"
+
+	MicrogramsPerCubicMeter Measurement = "μg/m³"
+	MilligramsPerCubicMeter Measurement = "mg/m³"
+	GramsPerCubicMeter      Measurement = "g/m³"
+	MicrogramsPerCubicFoot  Measurement = "μg/ft³"
+	PartsPerCubicMeter      Measurement = "p/m³"
+	PartsPerMillion         Measurement = "ppm"
+	PartsPerBillion         Measurement = "ppb"
 
-	Millimeter Measurement = "mm"
-	Centimeter Measurement = "cm"
+	Millimeter   Measurement = "mm"
+	Centimeter   Measurement = "cm"
+	Meter        Measurement = "m"
+	Kilometer    Measurement = "km"
+	Inch         Measurement = "in"
+	Foot         Measurement = "ft"
+	Yard         Measurement = "yd"
+	Mile         Measurement = "mi"
+	NauticalMile Measurement = "nmi"
+
+	SquareMillimeter Measurement = "mm²"
+	SquareCentimeter Measurement = "cm²"
+	SquareMeter      Measurement = "m²"
+	SquareKilometer  Measurement = "km²"
+	SquareInch       Measurement = "in²"
+	SquareFoot       Measurement = "ft²"
+	SquareYard       Measurement = "yd²"
+	SquareMile       Measurement = "mi²"
+	Acre             Measurement = "ac"
+	Hectare          Measurement = "ha"
 
 	MilliWattHour Measurement = "mWh"
 	WattHour      Measurement = "Wh"
 	KiloWattHour  Measurement = "kWh"
 	MegaWattHour  Measurement = "MWh"
 	GigaWattHour  Measurement = "GWh"
+	TeraWattHour  Measurement = "TWh"
+
+	KiloWattHourPer100Kilometer Measurement = "kWh/100km"
+	WattHourPerKilometer        Measurement = "Wh/km"
+	MilePerKiloWattHour         Measurement = "mi/kWh"
+	KilometerPerKiloWattHour    Measurement = "km/kWh"
 
-	MicroVolt Measurement = "µV"
+	MilliWatt  Measurement = "mW"
+	Watt       Measurement = "W"
+	KiloWatt   Measurement = "kW"
+	MegaWatt   Measurement = "MW"
+	GigaWatt   Measurement = "GW"
+	TeraWatt   Measurement = "TW"
+	BTUPerHour Measurement = "BTU/h"
+
+	VoltAmpereReactive         Measurement = "var"
+	MilliVoltAmpereReactive    Measurement = "mvar"
+	KiloVoltAmpereReactive     Measurement = "kvar"
+	VoltAmpereReactiveHour     Measurement = "varh"
+	KiloVoltAmpereReactiveHour Measurement = "kvarh"
+
+	MicroVolt Measurement = "μV"
 	MilliVolt Measurement = "mV"
 	Volt      Measurement = "V"
 	KiloVolt  Measurement = "kV"
 	MegaVolt  Measurement = "MV"
 
 	Percent Measurement = "%"
+
+	MicroSecond Measurement = "μs"
+	MilliSecond Measurement = "ms"
+	Second      Measurement = "s"
+	Minute      Measurement = "min"
+	Hour        Measurement = "h"
+	Day         Measurement = "d"
+	Week        Measurement = "w"
+	Month       Measurement = "month"
+	Year        Measurement = "y"
+
+	Hertz     Measurement = "Hz"
+	KiloHertz Measurement = "kHz"
+	MegaHertz Measurement = "MHz"
+	GigaHertz Measurement = "GHz"
+
+	Pascal              Measurement = "Pa"
+	HectoPascal         Measurement = "hPa"
+	KiloPascal          Measurement = "kPa"
+	Bar                 Measurement = "bar"
+	CentiBar            Measurement = "cbar"
+	MilliBar            Measurement = "mbar"
+	MillimeterOfMercury Measurement = "mmHg"
+	InchOfMercury       Measurement = "inHg"
+	InchOfWater         Measurement = "inH₂O"
+	PSI                 Measurement = "psi"
+
+	Decibel          Measurement = "dB"
+	DecibelAWeighted Measurement = "dBA"
+	DecibelMilliwatt Measurement = "dBm"
+
+	Milliliter      Measurement = "mL"
+	Liter           Measurement = "L"
+	CubicMeter      Measurement = "m³"
+	CubicFoot       Measurement = "ft³"
+	CentumCubicFoot Measurement = "CCF"
+	MilleCubicFoot  Measurement = "MCF"
+	Gallon          Measurement = "gal"
+	FluidOunce      Measurement = "fl. oz."
+
+	CubicMeterPerHour   Measurement = "m³/h"
+	CubicMeterPerSecond Measurement = "m³/s"
+	CubicFootPerMinute  Measurement = "ft³/min"
+	LiterPerHour        Measurement = "L/h"
+	LiterPerMinute      Measurement = "L/min"
+	LiterPerSecond      Measurement = "L/s"
+	GallonPerMinute     Measurement = "gal/min"
+	GallonPerDay        Measurement = "gal/d"
+	MilliliterPerSecond Measurement = "mL/s"
+
+	InchPerDay        Measurement = "in/d"
+	InchPerHour       Measurement = "in/h"
+	MillimeterPerDay  Measurement = "mm/d"
+	MillimeterPerHour Measurement = "mm/h"
+
+	Beaufort            Measurement = "Beaufort"
+	FootPerSecond       Measurement = "ft/s"
+	InchPerSecond       Measurement = "in/s"
+	MeterPerSecond      Measurement = "m/s"
+	KilometerPerHour    Measurement = "km/h"
+	Knot                Measurement = "kn"
+	MilePerHour         Measurement = "mph"
+	MillimeterPerSecond Measurement = "mm/s"
+
+	Microgram Measurement = "μg"
+	Milligram Measurement = "mg"
+	Gram      Measurement = "g"
+	Kilogram  Measurement = "kg"
+	Ounce     Measurement = "oz"
+	Pound     Measurement = "lb"
+	Stone     Measurement = "st"
+
+	Bit       Measurement = "bit"
+	Kilobit   Measurement = "kbit"
+	Megabit   Measurement = "Mbit"
+	Gigabit   Measurement = "Gbit"
+	Byte      Measurement = "B"
+	Kilobyte  Measurement = "kB"
+	Megabyte  Measurement = "MB"
+	Gigabyte  Measurement = "GB"
+	Terabyte  Measurement = "TB"
+	Petabyte  Measurement = "PB"
+	Exabyte   Measurement = "EB"
+	Zettabyte Measurement = "ZB"
+	Yottabyte Measurement = "YB"
+	Kibibyte  Measurement = "KiB"
+	Mebibyte  Measurement = "MiB"
+	Gibibyte  Measurement = "GiB"
+	Tebibyte  Measurement = "TiB"
+	Pebibyte  Measurement = "PiB"
+	Exbibyte  Measurement = "EiB"
+	Zebibyte  Measurement = "ZiB"
+	Yobibyte  Measurement = "YiB"
+
+	BitPerSecond      Measurement = "bit/s"
+	KilobitPerSecond  Measurement = "kbit/s"
+	MegabitPerSecond  Measurement = "Mbit/s"
+	GigabitPerSecond  Measurement = "Gbit/s"
+	BytePerSecond     Measurement = "B/s"
+	KilobytePerSecond Measurement = "kB/s"
+	MegabytePerSecond Measurement = "MB/s"
+	GigabytePerSecond Measurement = "GB/s"
+	KibibytePerSecond Measurement = "KiB/s"
+	MebibytePerSecond Measurement = "MiB/s"
+	GibibytePerSecond Measurement = "GiB/s"
 )